@@ -3,56 +3,255 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/user/importer/internal/blob"
 	"github.com/user/importer/internal/config"
 	"github.com/user/importer/internal/db"
 	"github.com/user/importer/internal/importer"
 	"github.com/user/importer/internal/jobs"
+	"github.com/user/importer/internal/products"
+	"github.com/user/importer/internal/schedule"
 )
 
 func main() {
+	// Subcommands come first: `cli migrate --dry-run`. Without one, the CLI
+	// enqueues from flags and runs workers.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	var customerID, productType, blobURI string
+	var priority int
+	var runAt, idempotencyKey string
 	flag.StringVar(&customerID, "customer", "", "customer id")
 	flag.StringVar(&productType, "product", "", "product type (users|organizations|courses)")
 	flag.StringVar(&blobURI, "file", "", "file path or file:// URI")
+	flag.IntVar(&priority, "priority", 0, "job priority; higher runs first")
+	flag.StringVar(&runAt, "run-at", "", "RFC 3339 time to run the job at, e.g. 2024-05-01T02:00:00Z")
+	flag.StringVar(&idempotencyKey, "idempotency-key", "", "key that makes a repeated enqueue return the original job")
 	flag.Parse()
 
-	var cfg config.AppConfig
-	if yamlPath := os.Getenv("CONFIG_PATH"); yamlPath != "" {
-		env := os.Getenv("CONFIG_ENV")
-		if env == "" { env = "default" }
-		c, err := config.LoadFromJSON(yamlPath, env)
-		if err != nil { log.Fatalf("load json config: %v", err) }
-		cfg = c
-	} else {
-		c, err := config.LoadConfig()
-		if err != nil { log.Fatalf("load config: %v", err) }
-		cfg = c
+	opts := jobs.EnqueueOptions{Priority: priority, IdempotencyKey: idempotencyKey}
+	if runAt != "" {
+		t, err := time.Parse(time.RFC3339, runAt)
+		if err != nil {
+			log.Fatalf("invalid --run-at: %v", err)
+		}
+		opts.RunAt = t
 	}
+
+	cfg := loadConfig()
 	ctx := context.Background()
 	adb, err := db.ConnectAppDB(ctx, cfg.AppPostgresDSN)
 	if err != nil {
 		log.Fatalf("connect app db: %v", err)
 	}
 	defer adb.Close()
+	if cfg.AutoMigrate {
+		if _, err := adb.Migrate(ctx); err != nil {
+			log.Fatalf("migrate app db: %v", err)
+		}
+	}
 	custMap, err := config.LoadCustomerMap(cfg.CustomerMapPath)
 	if err != nil {
 		log.Fatalf("load customer map: %v", err)
 	}
 	jr := jobs.NewRepository(adb)
-	imp := importer.NewService(blob.FileBlob{}, jr, custMap)
+	jr.MaxAttempts = cfg.MaxAttempts
+	jr.MaxRunningPerCustomer = cfg.MaxRunningPerCustomer
+	blobs, err := cfg.BlobRegistry(custMap)
+	if err != nil {
+		log.Fatalf("blob registry: %v", err)
+	}
+	log.Printf("Blob schemes: %s", strings.Join(blobs.Schemes(), ", "))
+	imp := importer.NewService(blobs, jr, custMap)
+	imp.RetryBaseDelay = time.Duration(cfg.RetryBaseDelaySec) * time.Second
+	imp.LeaseTTL = time.Duration(cfg.LeaseTTLSec) * time.Second
+	imp.DedupContent = cfg.DedupContent
 
 	// Enqueue and run worker in foreground until context cancelled; or if flags omitted, enqueue only
 	if customerID != "" && productType != "" && blobURI != "" {
-		if _, err := jr.Enqueue(ctx, customerID, productType, blobURI); err != nil {
+		id, err := imp.Enqueue(ctx, customerID, productType, blobURI, opts)
+		if err != nil {
 			log.Fatalf("enqueue: %v", err)
 		}
+		fmt.Printf("job %d enqueued\n", id)
 	}
 
+	go schedule.NewScheduler(schedule.NewRepository(adb, blobs), jr).Run(ctx)
 	imp.Worker(ctx, cfg.WorkerConcurrency)
 }
 
+func runCommand(name string, args []string) {
+	switch name {
+	case "migrate":
+		migrateApp(args)
+	case "migrate-customers":
+		migrateCustomers(args)
+	case "replay":
+		replayJob(args)
+	case "cancel":
+		cancelJob(args)
+	case "schedules":
+		schedulesCommand(args)
+	default:
+		log.Fatalf("unknown command %q (available: migrate, migrate-customers, replay, cancel, schedules)", name)
+	}
+}
+
+// cancelJob cancels a queued job, or asks the worker running it to stop.
+func cancelJob(args []string) {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	jobID := fs.Int64("job", 0, "id of the queued or running job to cancel")
+	rollback := fs.Bool("rollback", false, "delete the rows a running job already inserted")
+	fs.Parse(args)
+	if *jobID <= 0 {
+		log.Fatal("--job is required")
+	}
+
+	cfg := loadConfig()
+	ctx := context.Background()
+	adb, err := db.ConnectAppDB(ctx, cfg.AppPostgresDSN)
+	if err != nil {
+		log.Fatalf("connect app db: %v", err)
+	}
+	defer adb.Close()
+	st, err := jobs.NewRepository(adb).Cancel(ctx, *jobID, *rollback)
+	if err != nil {
+		log.Fatalf("cancel: %v", err)
+	}
+	if st == jobs.StatusCancelled {
+		fmt.Printf("job %d cancelled\n", *jobID)
+		return
+	}
+	fmt.Printf("job %d is running; cancellation requested\n", *jobID)
+}
+
+// replayJob requeues a dead or failed job with a fresh set of attempts.
+func replayJob(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	jobID := fs.Int64("job", 0, "id of the dead or failed job to replay")
+	fs.Parse(args)
+	if *jobID <= 0 {
+		log.Fatal("--job is required")
+	}
+
+	cfg := loadConfig()
+	ctx := context.Background()
+	adb, err := db.ConnectAppDB(ctx, cfg.AppPostgresDSN)
+	if err != nil {
+		log.Fatalf("connect app db: %v", err)
+	}
+	defer adb.Close()
+	if err := jobs.NewRepository(adb).Replay(ctx, *jobID); err != nil {
+		log.Fatalf("replay: %v", err)
+	}
+	fmt.Printf("job %d requeued\n", *jobID)
+}
+
+// migrateApp applies pending app DB migrations, for deployments that set auto_migrate to false.
+func migrateApp(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	fs.Parse(args)
 
+	cfg := loadConfig()
+	ctx := context.Background()
+	adb, err := db.ConnectAppDB(ctx, cfg.AppPostgresDSN)
+	if err != nil {
+		log.Fatalf("connect app db: %v", err)
+	}
+	defer adb.Close()
+
+	verb := "applied"
+	var migs []db.Migration
+	if *dryRun {
+		verb = "pending"
+		migs, err = adb.PendingMigrations(ctx)
+	} else {
+		migs, err = adb.Migrate(ctx)
+	}
+	for _, m := range migs {
+		fmt.Printf("%s\t%d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("migrate app db: %v", err)
+	}
+	if len(migs) == 0 {
+		fmt.Println("app db is up to date")
+	}
+}
+
+// migrateCustomers applies pending target table migrations in every customer DB of the customer map.
+func migrateCustomers(args []string) {
+	fs := flag.NewFlagSet("migrate-customers", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	only := fs.String("customer", "", "migrate only this customer id")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+	custMap, err := config.LoadCustomerMap(cfg.CustomerMapPath)
+	if err != nil {
+		log.Fatalf("load customer map: %v", err)
+	}
+	if *only != "" {
+		target, ok := custMap[*only]
+		if !ok {
+			log.Fatalf("unknown customer id: %s", *only)
+		}
+		custMap = config.CustomerDBMap{*only: target}
+	}
+
+	verb := "applied"
+	if *dryRun {
+		verb = "pending"
+	}
+	failed := 0
+	for _, res := range importer.MigrateCustomers(context.Background(), custMap, *dryRun) {
+		for _, pt := range products.Types() {
+			migs, ok := res.Migrations[pt]
+			if !ok {
+				continue
+			}
+			names := make([]string, 0, len(migs))
+			for _, m := range migs {
+				names = append(names, fmt.Sprintf("%d_%s", m.Version, m.Name))
+			}
+			if len(names) == 0 {
+				names = append(names, "none")
+			}
+			fmt.Printf("%s\t%s\t%s: %s\n", res.CustomerID, pt, verb, strings.Join(names, ", "))
+		}
+		if res.Err != nil {
+			failed++
+			fmt.Printf("%s\terror: %v\n", res.CustomerID, res.Err)
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("migrations failed for %d customer(s)", failed)
+	}
+}
+
+func loadConfig() config.AppConfig {
+	if yamlPath := os.Getenv("CONFIG_PATH"); yamlPath != "" {
+		env := os.Getenv("CONFIG_ENV")
+		if env == "" {
+			env = "default"
+		}
+		c, err := config.LoadFromJSON(yamlPath, env)
+		if err != nil {
+			log.Fatalf("load json config: %v", err)
+		}
+		return c
+	}
+	c, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+	return c
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/user/importer/internal/config"
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/schedule"
+)
+
+// schedulesCommand manages recurring import schedules:
+//
+//	cli schedules list [--customer c]
+//	cli schedules get --id N
+//	cli schedules create --customer c --product p --uri tmpl --cron expr [--tz zone] [--priority n] [--enabled=false]
+//	cli schedules update --id N [any create flag]
+//	cli schedules delete --id N
+func schedulesCommand(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: cli schedules list|get|create|update|delete [flags]")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("schedules "+action, flag.ExitOnError)
+	id := fs.Int64("id", 0, "schedule id")
+	customerID := fs.String("customer", "", "customer id")
+	productType := fs.String("product", "", "product type (users|organizations|courses)")
+	uri := fs.String("uri", "", "blob URI template, e.g. file:///data/users-{{date:2006-01-02}}.csv")
+	cronExpr := fs.String("cron", "", "cron expression: minute hour day-of-month month day-of-week")
+	tz := fs.String("tz", "", "IANA timezone of the cron expression (default UTC)")
+	priority := fs.Int("priority", 0, "priority of the enqueued jobs")
+	enabled := fs.Bool("enabled", true, "whether the schedule fires")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+	ctx := context.Background()
+	adb, err := db.ConnectAppDB(ctx, cfg.AppPostgresDSN)
+	if err != nil {
+		log.Fatalf("connect app db: %v", err)
+	}
+	defer adb.Close()
+	custMap, err := config.LoadCustomerMap(cfg.CustomerMapPath)
+	if err != nil {
+		log.Fatalf("load customer map: %v", err)
+	}
+	blobs, err := cfg.BlobRegistry(custMap)
+	if err != nil {
+		log.Fatalf("blob registry: %v", err)
+	}
+	sr := schedule.NewRepository(adb, blobs)
+
+	requireID := func() {
+		if *id <= 0 {
+			log.Fatal("--id is required")
+		}
+	}
+	var out any
+	switch action {
+	case "list":
+		out, err = sr.List(ctx, *customerID)
+	case "get":
+		requireID()
+		out, err = sr.Get(ctx, *id)
+	case "create":
+		out, err = sr.Create(ctx, schedule.Schedule{
+			CustomerID:      *customerID,
+			ProductType:     *productType,
+			BlobURITemplate: *uri,
+			Cron:            *cronExpr,
+			Timezone:        *tz,
+			Priority:        *priority,
+			Enabled:         *enabled,
+		})
+	case "update":
+		requireID()
+		var s *schedule.Schedule
+		if s, err = sr.Get(ctx, *id); err != nil {
+			break
+		}
+		// only the flags given change
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "customer":
+				s.CustomerID = *customerID
+			case "product":
+				s.ProductType = *productType
+			case "uri":
+				s.BlobURITemplate = *uri
+			case "cron":
+				s.Cron = *cronExpr
+			case "tz":
+				s.Timezone = *tz
+			case "priority":
+				s.Priority = *priority
+			case "enabled":
+				s.Enabled = *enabled
+			}
+		})
+		out, err = sr.Update(ctx, *s)
+	case "delete":
+		requireID()
+		if err = sr.Delete(ctx, *id); err == nil {
+			fmt.Printf("schedule %d deleted\n", *id)
+			return
+		}
+	default:
+		log.Fatalf("unknown schedules action %q (available: list, get, create, update, delete)", action)
+	}
+	if err != nil {
+		log.Fatalf("schedules %s: %v", action, err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(out)
+}
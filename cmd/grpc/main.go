@@ -6,33 +6,39 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
-	"github.com/user/importer/internal/blob"
+	importerv1 "github.com/user/importer/api/importer/v1"
 	"github.com/user/importer/internal/config"
 	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/grpcsvc"
 	"github.com/user/importer/internal/importer"
 	"github.com/user/importer/internal/jobs"
-	"github.com/user/importer/internal/grpcsvc"
 )
 
-// Simple gRPC service definition without proto files using grpc.ServiceRegistrar via reflection is limited;
-// For demo, we keep the server running and log that gRPC would be implemented with protobufs.
+// Serves the typed importer.v1.Importer service and the deprecated Struct-based importer.Importer.
 
 func main() {
 	var cfg config.AppConfig
 	if yamlPath := os.Getenv("CONFIG_PATH"); yamlPath != "" {
 		env := os.Getenv("CONFIG_ENV")
-		if env == "" { env = "default" }
+		if env == "" {
+			env = "default"
+		}
 		c, err := config.LoadFromJSON(yamlPath, env)
-		if err != nil { log.Fatalf("load json config: %v", err) }
+		if err != nil {
+			log.Fatalf("load json config: %v", err)
+		}
 		cfg = c
 	} else {
 		c, err := config.LoadConfig()
-		if err != nil { log.Fatalf("load config: %v", err) }
+		if err != nil {
+			log.Fatalf("load config: %v", err)
+		}
 		cfg = c
 	}
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -43,12 +49,27 @@ func main() {
 		log.Fatalf("connect app db: %v", err)
 	}
 	defer adb.Close()
+	if cfg.AutoMigrate {
+		if _, err := adb.Migrate(ctx); err != nil {
+			log.Fatalf("migrate app db: %v", err)
+		}
+	}
 	custMap, err := config.LoadCustomerMap(cfg.CustomerMapPath)
 	if err != nil {
 		log.Fatalf("load customer map: %v", err)
 	}
 	jr := jobs.NewRepository(adb)
-	_ = importer.NewService(blob.FileBlob{}, jr, custMap)
+	jr.MaxAttempts = cfg.MaxAttempts
+	blobs, err := cfg.BlobRegistry(custMap)
+	if err != nil {
+		log.Fatalf("blob registry: %v", err)
+	}
+	log.Printf("Blob schemes: %s", strings.Join(blobs.Schemes(), ", "))
+	imp := importer.NewService(blobs, jr, custMap)
+	imp.DedupContent = cfg.DedupContent
+	imp.BlobWriter = blobs
+	imp.UploadStagingURI = cfg.UploadStagingURI
+	imp.MaxUploadBytes = int64(cfg.MaxUploadBytes)
 
 	l, err := net.Listen("tcp", cfg.GRPCAddr)
 	if err != nil {
@@ -56,13 +77,14 @@ func main() {
 	}
 	s := grpc.NewServer()
 	// Register our manual service descriptor
-	grpcServer := grpcsvc.New(jr)
+	hub := jobs.NewHub(jr)
+	go hub.Run(ctx)
+	grpcServer := grpcsvc.New(jr, imp, hub)
 	s.RegisterService(&grpcsvc.ImporterServiceDesc, grpcServer)
+	importerv1.RegisterImporterServer(s, grpcsvc.NewV1(grpcServer))
 	reflection.Register(s)
-	log.Printf("gRPC listening on %s. Service: importer.Importer/Enqueue (Struct).", cfg.GRPCAddr)
+	log.Printf("gRPC listening on %s. Services: importer.v1.Importer (typed), importer.Importer (Struct, deprecated).", cfg.GRPCAddr)
 	if err := s.Serve(l); err != nil {
 		log.Fatalf("grpc: %v", err)
 	}
 }
-
-
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/importer/internal/jobs"
+)
+
+// sseKeepAlive is how often an idle event stream sends a comment, so proxies do not
+// close it and clients notice dead connections.
+const sseKeepAlive = 15 * time.Second
+
+// registerEventRoutes adds the job event stream to the default mux.
+//
+// GET /jobs/{id}/events is a Server-Sent Events stream: a "status" event with the job
+// first, then "status", "progress" and "log" events as they happen. After the job
+// reaches a terminal status an "end" event is sent and the stream closes. Log events carry the log id as the event id, so a
+// reconnecting client (Last-Event-ID, or ?after=) receives only newer entries.
+func registerEventRoutes(hub *jobs.Hub) {
+	http.HandleFunc("GET /jobs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jobIDParam(w, r)
+		if !ok {
+			return
+		}
+		after, err := int64Query(r, "after")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			if after, err = strconv.ParseInt(v, 10, 64); err != nil {
+				writeError(w, http.StatusBadRequest, errors.New("invalid Last-Event-ID"))
+				return
+			}
+		}
+		// 404 before the stream starts
+		if _, err := hub.Repo.Get(r.Context(), id); err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		var mu sync.Mutex
+		send := func(frame string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := w.Write([]byte(frame)); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			t := time.NewTicker(sseKeepAlive)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					if send(": keep-alive\n\n") != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		err = hub.Watch(ctx, id, after, func(ev jobs.Event) error {
+			var data any = ev.Job
+			idLine := ""
+			if ev.Type == jobs.EventLog {
+				data = ev.Log
+				idLine = fmt.Sprintf("id: %d\n", ev.Log.ID)
+			}
+			b, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			return send(fmt.Sprintf("event: %s\n%sdata: %s\n\n", ev.Type, idLine, b))
+		})
+		switch {
+		case err == nil:
+			// EventSource clients reconnect when a stream closes; this tells them not to
+			send("event: end\ndata: {}\n\n")
+		case ctx.Err() == nil:
+			b, _ := json.Marshal(map[string]string{"error": err.Error()})
+			send(fmt.Sprintf("event: error\ndata: %s\n\n", b))
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/user/importer/internal/jobs"
+)
+
+// registerJobRoutes adds the job query endpoints to the default mux.
+func registerJobRoutes(jr *jobs.Repository) {
+	http.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := jobs.ListFilter{
+			CustomerID:  q.Get("customer_id"),
+			ProductType: q.Get("product_type"),
+			Status:      jobs.Status(q.Get("status")),
+			Cursor:      q.Get("cursor"),
+		}
+		var err error
+		if f.CreatedFrom, err = timeQuery(r, "created_from"); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if f.CreatedTo, err = timeQuery(r, "created_to"); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		limit, err := int64Query(r, "limit")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		f.Limit = int(limit)
+		list, next, err := jr.List(r.Context(), f)
+		if err != nil {
+			if errors.Is(err, jobs.ErrInvalidCursor) {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, map[string]any{"jobs": list, "next_cursor": next})
+	})
+
+	http.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jobIDParam(w, r)
+		if !ok {
+			return
+		}
+		j, err := jr.Get(r.Context(), id)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, j)
+	})
+
+	http.HandleFunc("GET /jobs/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jobIDParam(w, r)
+		if !ok {
+			return
+		}
+		after, err := int64Query(r, "after")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		limit, err := int64Query(r, "limit")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// 404 for unknown jobs rather than an empty page
+		if _, err := jr.Get(r.Context(), id); err != nil {
+			writeJobError(w, err)
+			return
+		}
+		logs, err := jr.Logs(r.Context(), id, after, int(limit))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		next := after
+		if len(logs) > 0 {
+			next = logs[len(logs)-1].ID
+		}
+		writeJSON(w, map[string]any{"logs": logs, "next_after": next})
+	})
+
+	http.HandleFunc("POST /jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jobIDParam(w, r)
+		if !ok {
+			return
+		}
+		rollback, err := strconv.ParseBool(valueOr(r.URL.Query().Get("rollback"), "false"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid rollback"))
+			return
+		}
+		st, err := jr.Cancel(r.Context(), id, rollback)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		// A running job keeps status running until its worker stops it.
+		writeJSON(w, map[string]any{"job_id": id, "status": st, "cancel_requested": true})
+	})
+
+	http.HandleFunc("POST /jobs/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := jobIDParam(w, r)
+		if !ok {
+			return
+		}
+		if err := jr.Replay(r.Context(), id); err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, map[string]any{"job_id": id, "status": jobs.StatusQueued})
+	})
+}
+
+// jobIDParam parses the {id} path value, writing a 400 when it is not a job id.
+func jobIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid job id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// int64Query parses an optional integer query parameter; absent means 0.
+func int64Query(r *http.Request, name string) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return n, nil
+}
+
+// timeQuery parses an optional RFC 3339 query parameter; absent means the zero time.
+func timeQuery(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid " + name + ": expected RFC 3339 time")
+	}
+	return t, nil
+}
+
+func valueOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	if errors.Is(err, jobs.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, jobs.ErrNotReplayable) || errors.Is(err, jobs.ErrNotCancellable) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.WriteHeader(code)
+	w.Write([]byte(err.Error()))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
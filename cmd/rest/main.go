@@ -3,17 +3,21 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/user/importer/internal/blob"
 	"github.com/user/importer/internal/config"
 	"github.com/user/importer/internal/db"
 	"github.com/user/importer/internal/importer"
 	"github.com/user/importer/internal/jobs"
+	"github.com/user/importer/internal/schedule"
 )
 
 func main() {
@@ -44,6 +48,11 @@ func main() {
 		log.Fatalf("connect app db: %v", err)
 	}
 	defer adb.Close()
+	if cfg.AutoMigrate {
+		if _, err := adb.Migrate(ctx); err != nil {
+			log.Fatalf("migrate app db: %v", err)
+		}
+	}
 
 	custMap, err := config.LoadCustomerMap(cfg.CustomerMapPath)
 	if err != nil {
@@ -51,7 +60,20 @@ func main() {
 	}
 
 	jr := jobs.NewRepository(adb)
-	imp := importer.NewService(blob.FileBlob{}, jr, custMap)
+	jr.MaxAttempts = cfg.MaxAttempts
+	jr.MaxRunningPerCustomer = cfg.MaxRunningPerCustomer
+	blobs, err := cfg.BlobRegistry(custMap)
+	if err != nil {
+		log.Fatalf("blob registry: %v", err)
+	}
+	log.Printf("Blob schemes: %s", strings.Join(blobs.Schemes(), ", "))
+	imp := importer.NewService(blobs, jr, custMap)
+	imp.RetryBaseDelay = time.Duration(cfg.RetryBaseDelaySec) * time.Second
+	imp.LeaseTTL = time.Duration(cfg.LeaseTTLSec) * time.Second
+	imp.DedupContent = cfg.DedupContent
+	imp.BlobWriter = blobs
+	imp.UploadStagingURI = cfg.UploadStagingURI
+	imp.MaxUploadBytes = int64(cfg.MaxUploadBytes)
 
 	if cfg.ParseBatchSize > 0 {
 		ctx = context.WithValue(ctx, "parse_batch_size", cfg.ParseBatchSize)
@@ -60,6 +82,9 @@ func main() {
 
 	// start worker
 	go imp.Worker(ctx, cfg.WorkerConcurrency)
+	// one replica at a time fires schedules
+	sr := schedule.NewRepository(adb, blobs)
+	go schedule.NewScheduler(sr, jr).Run(ctx)
 
 	// Serve swagger at /swagger and spec at /swagger/doc.json
 	http.HandleFunc("/swagger", func(w http.ResponseWriter, r *http.Request) {
@@ -104,14 +129,36 @@ func main() {
 			CustomerID  string `json:"customer_id"`
 			ProductType string `json:"product_type"`
 			BlobURI     string `json:"blob_uri"`
+			Priority    int    `json:"priority"`
+			// RFC 3339; omitted runs the job as soon as possible
+			RunAt time.Time `json:"run_at"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
-		id, err := jr.Enqueue(r.Context(), req.CustomerID, req.ProductType, req.BlobURI)
+		id, err := imp.Enqueue(r.Context(), req.CustomerID, req.ProductType, req.BlobURI, jobs.EnqueueOptions{
+			Priority:       req.Priority,
+			RunAt:          req.RunAt,
+			IdempotencyKey: r.Header.Get("Idempotency-Key"),
+		})
 		if err != nil {
+			if errors.Is(err, jobs.ErrIdempotencyConflict) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if errors.Is(err, blob.ErrUnsupportedScheme) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			if errors.Is(err, blob.ErrOutsideRoots) {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(err.Error()))
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(err.Error()))
 			return
@@ -119,6 +166,13 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]any{"job_id": id})
 	})
 
+	registerJobRoutes(jr)
+	hub := jobs.NewHub(jr)
+	go hub.Run(ctx)
+	registerEventRoutes(hub)
+	registerScheduleRoutes(sr)
+	registerUploadRoutes(imp)
+
 	log.Printf("REST listening on %s", cfg.RESTAddr)
 	if err := http.ListenAndServe(cfg.RESTAddr, nil); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("http: %v", err)
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/user/importer/internal/schedule"
+)
+
+// scheduleRequest is the body of schedule create and update requests.
+type scheduleRequest struct {
+	CustomerID      string `json:"customer_id"`
+	ProductType     string `json:"product_type"`
+	BlobURITemplate string `json:"blob_uri_template"`
+	Cron            string `json:"cron"`
+	Timezone        string `json:"timezone"`
+	Priority        int    `json:"priority"`
+	// omitted means enabled
+	Enabled *bool `json:"enabled"`
+}
+
+func (req scheduleRequest) schedule(id int64) schedule.Schedule {
+	s := schedule.Schedule{
+		ID:              id,
+		CustomerID:      req.CustomerID,
+		ProductType:     req.ProductType,
+		BlobURITemplate: req.BlobURITemplate,
+		Cron:            req.Cron,
+		Timezone:        req.Timezone,
+		Priority:        req.Priority,
+		Enabled:         true,
+	}
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+	return s
+}
+
+// registerScheduleRoutes adds the schedule CRUD endpoints to the default mux.
+func registerScheduleRoutes(sr *schedule.Repository) {
+	http.HandleFunc("GET /schedules", func(w http.ResponseWriter, r *http.Request) {
+		list, err := sr.List(r.Context(), r.URL.Query().Get("customer_id"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, map[string]any{"schedules": list})
+	})
+
+	http.HandleFunc("POST /schedules", func(w http.ResponseWriter, r *http.Request) {
+		var req scheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s, err := sr.Create(r.Context(), req.schedule(0))
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, s)
+	})
+
+	http.HandleFunc("GET /schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := scheduleIDParam(w, r)
+		if !ok {
+			return
+		}
+		s, err := sr.Get(r.Context(), id)
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		writeJSON(w, s)
+	})
+
+	// PUT replaces every field; the next run is recomputed from now.
+	http.HandleFunc("PUT /schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := scheduleIDParam(w, r)
+		if !ok {
+			return
+		}
+		var req scheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s, err := sr.Update(r.Context(), req.schedule(id))
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		writeJSON(w, s)
+	})
+
+	http.HandleFunc("DELETE /schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := scheduleIDParam(w, r)
+		if !ok {
+			return
+		}
+		if err := sr.Delete(r.Context(), id); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func scheduleIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid schedule id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func writeScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, schedule.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, schedule.ErrInvalid):
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
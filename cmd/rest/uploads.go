@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/user/importer/internal/importer"
+	"github.com/user/importer/internal/jobs"
+)
+
+const (
+	// maxFormValueBytes bounds each non-file field of a multipart upload.
+	maxFormValueBytes = 1 << 10
+	// maxFormOverhead is allowed on top of MaxUploadBytes for multipart boundaries and fields.
+	maxFormOverhead = 64 << 10
+)
+
+// registerUploadRoutes adds the file upload endpoints to the default mux.
+func registerUploadRoutes(imp *importer.Service) {
+	// POST /uploads takes either multipart/form-data with the fields before the file
+	// part, or the raw file as the body with the metadata in X- headers.
+	http.HandleFunc("POST /uploads", func(w http.ResponseWriter, r *http.Request) {
+		if imp.MaxUploadBytes > 0 {
+			if r.ContentLength > imp.MaxUploadBytes+maxFormOverhead {
+				writeError(w, http.StatusRequestEntityTooLarge, importer.ErrUploadTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, imp.MaxUploadBytes+maxFormOverhead)
+		}
+		var u importer.Upload
+		var body io.Reader
+		var err error
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "multipart/form-data" {
+			u, body, err = multipartUpload(r)
+		} else {
+			u, err = headerUpload(r)
+			body = r.Body
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		rec, err := imp.Upload(r.Context(), u, body)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/uploads/%d", rec.ID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rec)
+	})
+
+	http.HandleFunc("GET /uploads/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("invalid upload id"))
+			return
+		}
+		u, err := imp.JobRepo.GetUpload(r.Context(), id)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		writeJSON(w, u)
+	})
+}
+
+// multipartUpload reads the form fields up to the "file" part and returns that part
+// as the body, so the file streams to the staging blob without being buffered.
+func multipartUpload(r *http.Request) (importer.Upload, io.Reader, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return importer.Upload{}, nil, err
+	}
+	var u importer.Upload
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return importer.Upload{}, nil, errors.New("missing file part")
+		}
+		if err != nil {
+			return importer.Upload{}, nil, err
+		}
+		if part.FormName() == "file" {
+			u.Filename = part.FileName()
+			u.ContentType = part.Header.Get("Content-Type")
+			return u, part, nil
+		}
+		v, err := formValue(part)
+		if err != nil {
+			return importer.Upload{}, nil, err
+		}
+		switch part.FormName() {
+		case "customer_id":
+			u.CustomerID = v
+		case "product_type":
+			u.ProductType = v
+		case "sha256":
+			u.SHA256 = v
+		case "priority":
+			if u.Priority, err = strconv.Atoi(v); err != nil {
+				return importer.Upload{}, nil, errors.New("invalid priority")
+			}
+		}
+	}
+}
+
+func formValue(part *multipart.Part) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(part, maxFormValueBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxFormValueBytes {
+		return "", fmt.Errorf("form field %s too long", part.FormName())
+	}
+	return string(b), nil
+}
+
+// headerUpload reads the metadata of a raw body upload from its headers.
+func headerUpload(r *http.Request) (importer.Upload, error) {
+	u := importer.Upload{
+		CustomerID:  r.Header.Get("X-Customer-Id"),
+		ProductType: r.Header.Get("X-Product-Type"),
+		Filename:    r.Header.Get("X-Filename"),
+		SHA256:      r.Header.Get("X-Content-Sha256"),
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	if v := r.Header.Get("X-Priority"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return importer.Upload{}, errors.New("invalid X-Priority")
+		}
+		u.Priority = p
+	}
+	return u, nil
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var dup *jobs.DuplicateContentError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &dup):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "duplicate_of_job_id": dup.JobID})
+	case errors.Is(err, importer.ErrUploadTooLarge), errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, importer.ErrInvalidUpload), errors.Is(err, importer.ErrChecksumMismatch):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, jobs.ErrUploadNotFound):
+		writeError(w, http.StatusNotFound, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
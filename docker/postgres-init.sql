@@ -0,0 +1,4 @@
+-- Creates the customer databases referenced by customer_map.json on first start.
+CREATE DATABASE customer1;
+CREATE DATABASE customer2;
+CREATE DATABASE shared;
@@ -6,6 +6,8 @@ toolchain go1.24.0
 
 require (
 	github.com/jackc/pgx/v5 v5.6.0
+	github.com/pkg/sftp v1.13.7
+	golang.org/x/crypto v0.27.0
 	google.golang.org/grpc v1.68.0
 	google.golang.org/protobuf v1.34.2
 )
@@ -14,7 +16,7 @@ require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/puddle/v2 v2.2.1 // indirect
-	golang.org/x/crypto v0.27.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	golang.org/x/net v0.29.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
@@ -0,0 +1,188 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azureAPIVersion is the Blob service REST API version requests are made with.
+const azureAPIVersion = "2021-08-06"
+
+// AzureConfig holds the credentials of one Azure storage account. With neither
+// AccountKey nor SASToken, requests are anonymous, which works for public containers
+// and for https:// URIs that carry their own SAS token.
+type AzureConfig struct {
+	AccountName string `json:"account_name"`
+	// AccountKey is the base64 shared key; requests are signed with Shared Key auth.
+	AccountKey string `json:"account_key"`
+	// SASToken is appended to requests instead, with or without the leading '?'.
+	SASToken string `json:"sas_token"`
+	// Endpoint overrides https://<account>.blob.core.windows.net, e.g.
+	// http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+	Endpoint string `json:"endpoint"`
+}
+
+// AzureBlob reads az://<container>/<blob> URIs from the configured account and
+// https://<account>.blob.core.windows.net/<container>/<blob> URLs. The account is
+// taken from Customers for the customer set with WithCustomer, or from Default.
+type AzureBlob struct {
+	Default   AzureConfig
+	Customers map[string]AzureConfig
+	// Client sends the requests; http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// Open implements Reader.
+func (b *AzureBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, uri)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Size implements Sizer.
+func (b *AzureBlob) Size(ctx context.Context, uri string) (int64, error) {
+	resp, err := b.do(ctx, http.MethodHead, uri)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+func (b *AzureBlob) config(ctx context.Context) AzureConfig {
+	if c, ok := b.Customers[CustomerFrom(ctx)]; ok {
+		return c
+	}
+	return b.Default
+}
+
+func (b *AzureBlob) do(ctx context.Context, method, uri string) (*http.Response, error) {
+	cfg := b.config(ctx)
+	target, account, err := cfg.url(uri)
+	if err != nil {
+		return nil, err
+	}
+	// the credentials only apply to their own account
+	if account != cfg.AccountName {
+		cfg = AzureConfig{}
+	}
+	if cfg.SASToken != "" && target.RawQuery == "" {
+		target.RawQuery = strings.TrimPrefix(cfg.SASToken, "?")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if cfg.AccountKey != "" && cfg.SASToken == "" {
+		if err := cfg.sign(req); err != nil {
+			return nil, err
+		}
+	}
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, newStatusError(method, uri, resp)
+	}
+	return resp, nil
+}
+
+// url resolves uri to the blob's request URL and the account it belongs to.
+func (c AzureConfig) url(uri string) (*url.URL, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case u.Scheme == "az":
+		if c.AccountName == "" {
+			return nil, "", errors.New("no azure account configured for " + uri)
+		}
+		if u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+			return nil, "", errors.New("invalid azure uri, expected az://container/blob: " + uri)
+		}
+		base := c.Endpoint
+		if base == "" {
+			base = "https://" + c.AccountName + ".blob.core.windows.net"
+		}
+		target, err := url.Parse(strings.TrimSuffix(base, "/") + "/" + u.Host + "/" + escapePath(strings.TrimPrefix(u.Path, "/")))
+		return target, c.AccountName, err
+	case u.Scheme == "https" && strings.HasSuffix(u.Host, ".blob.core.windows.net"):
+		return u, strings.TrimSuffix(u.Host, ".blob.core.windows.net"), nil
+	}
+	return nil, "", errors.New("not an azure blob uri: " + uri)
+}
+
+// sign adds a Shared Key Authorization header for the Blob service.
+func (c AzureConfig) sign(req *http.Request) error {
+	key, err := base64.StdEncoding.DecodeString(c.AccountKey)
+	if err != nil {
+		return errors.New("azure account_key is not valid base64")
+	}
+	var ms []string
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-ms-") {
+			ms = append(ms, lk)
+		}
+	}
+	sort.Strings(ms)
+	var canonHeaders strings.Builder
+	for _, k := range ms {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+	resource := "/" + c.AccountName + req.URL.EscapedPath()
+	q := req.URL.Query()
+	params := make([]string, 0, len(q))
+	for k := range q {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		resource += "\n" + strings.ToLower(k) + ":" + strings.Join(vals, ",")
+	}
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	toSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, sent as x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonHeaders.String() + resource,
+	}, "\n")
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(toSign))
+	req.Header.Set("Authorization", "SharedKey "+c.AccountName+":"+base64.StdEncoding.EncodeToString(m.Sum(nil)))
+	return nil
+}
@@ -0,0 +1,153 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// devstoreKey is the well-known key of the Azurite development account.
+const devstoreKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+// Signatures the Azure SDK for Go (azblob v1.4.0) computed for the same requests.
+func TestAzureSharedKeyVectors(t *testing.T) {
+	cfg := AzureConfig{AccountName: "devstoreaccount1", AccountKey: devstoreKey}
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		want    string
+	}{
+		{"escaped path", "http://127.0.0.1:10000/devstoreaccount1/cont/dir%2Fa%20b+c.csv",
+			map[string]string{"X-Ms-Date": "Sun, 18 Oct 2026 17:56:11 GMT", "X-Ms-Version": "2024-05-04"},
+			"SharedKey devstoreaccount1:Hfl7ixNgMyXIjtASzRGNFpBuKLh9ZUuJBFNUmdsVqL4="},
+		{"query and range", "http://127.0.0.1:10000/devstoreaccount1/cont/users.csv?snapshot=2024-01-01T00%3A00%3A00.0000000Z",
+			map[string]string{"X-Ms-Date": "Sun, 18 Oct 2026 17:56:11 GMT", "X-Ms-Version": "2024-05-04", "X-Ms-Range": "bytes=0-9", "Accept": "application/xml"},
+			"SharedKey devstoreaccount1:ajphb7xpAbZo/h5M/McLhk3h/YkZjLxJDhmM4HF6NrM="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if err := cfg.sign(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzureSignInvalidKey(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://acct.blob.core.windows.net/c/b", nil)
+	if err := (AzureConfig{AccountName: "acct", AccountKey: "not base64!"}).sign(req); err == nil {
+		t.Fatal("sign with an invalid key succeeded")
+	}
+}
+
+// azureServer records the requests of an Azure blob endpoint and serves body.
+func azureServer(t *testing.T, body string) (*httptest.Server, *[]*http.Request) {
+	var reqs []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs = append(reqs, r.Clone(context.Background()))
+		if r.URL.Path != "/devstoreaccount1/cont/dir/users.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.</Message></Error>`)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestAzureBlobAuth(t *testing.T) {
+	srv, reqs := azureServer(t, "id\n1\n")
+	endpoint := srv.URL + "/devstoreaccount1"
+	tests := []struct {
+		name      string
+		cfg       AzureConfig
+		wantAuth  bool
+		wantQuery string
+	}{
+		{"shared key", AzureConfig{AccountName: "devstoreaccount1", AccountKey: devstoreKey, Endpoint: endpoint}, true, ""},
+		{"sas token", AzureConfig{AccountName: "devstoreaccount1", SASToken: "?sv=2022-11-02&sig=abc", Endpoint: endpoint}, false, "sv=2022-11-02&sig=abc"},
+		{"anonymous", AzureConfig{AccountName: "devstoreaccount1", Endpoint: endpoint}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*reqs = nil
+			b := &AzureBlob{Default: tt.cfg}
+			rc, err := b.Open(context.Background(), "az://cont/dir/users.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc.Close()
+			r := (*reqs)[0]
+			if auth := r.Header.Get("Authorization"); (auth != "") != tt.wantAuth {
+				t.Errorf("Authorization = %q, want one %t", auth, tt.wantAuth)
+			}
+			if r.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", r.URL.RawQuery, tt.wantQuery)
+			}
+			if r.Header.Get("X-Ms-Version") != azureAPIVersion || r.Header.Get("X-Ms-Date") == "" {
+				t.Errorf("missing x-ms headers: %v", r.Header)
+			}
+		})
+	}
+}
+
+func TestAzureBlobNotFound(t *testing.T) {
+	srv, _ := azureServer(t, "")
+	b := &AzureBlob{Default: AzureConfig{AccountName: "devstoreaccount1", Endpoint: srv.URL + "/devstoreaccount1"}}
+	_, err := b.Open(context.Background(), "az://cont/missing.csv")
+	var se *StatusError
+	if !errors.Is(err, fs.ErrNotExist) || !errors.As(err, &se) || se.Code != "BlobNotFound" {
+		t.Fatalf("Open: err = %v, want BlobNotFound matching fs.ErrNotExist", err)
+	}
+}
+
+// redirectTransport sends every request to target, keeping its path and query, so
+// https://<account>.blob.core.windows.net URLs reach a test server.
+type redirectTransport struct{ target string }
+
+func (rt redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = "http", rt.target
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestAzureBlobSASURL(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Clone(context.Background())
+		io.WriteString(w, "id\n1\n")
+	}))
+	defer srv.Close()
+	// the account key belongs to another account, so it must not sign this URL
+	b := &AzureBlob{
+		Default: AzureConfig{AccountName: "other", AccountKey: devstoreKey},
+		Client:  &http.Client{Transport: redirectTransport{target: srv.Listener.Addr().String()}},
+	}
+	rc, err := b.Open(context.Background(), "https://partner.blob.core.windows.net/exports/users.csv?sv=2022-11-02&sr=b&sig=a%2Fb%3D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if got.URL.Path != "/exports/users.csv" || got.URL.Query().Get("sig") != "a/b=" {
+		t.Fatalf("request = %s, want the SAS URL unchanged", got.URL)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "" {
+		t.Fatalf("Authorization = %q, want none", auth)
+	}
+}
@@ -10,4 +10,41 @@ type Reader interface {
 	Open(ctx context.Context, uri string) (io.ReadCloser, error)
 }
 
+// Sizer is implemented by readers that can report a blob's size in bytes without
+// reading it; it lets job progress report a percentage.
+type Sizer interface {
+	Size(ctx context.Context, uri string) (int64, error)
+}
+
+// Checker is implemented by readers that can tell up front whether they serve a URI,
+// for the customer set with WithCustomer.
+type Checker interface {
+	Check(ctx context.Context, uri string) error
+}
+
+// Writer creates blob objects. Content written to the returned WriteCloser becomes
+// visible under uri only when Close returns nil; cancelling ctx before Close discards it.
+type Writer interface {
+	Create(ctx context.Context, uri string) (io.WriteCloser, error)
+}
+
+// Deleter is implemented by writers that can remove a blob they created.
+type Deleter interface {
+	Delete(ctx context.Context, uri string) error
+}
+
+type customerKey struct{}
+
+// WithCustomer tags ctx with the customer whose blob is accessed, so readers with
+// per-customer credentials can pick them.
+func WithCustomer(ctx context.Context, customerID string) context.Context {
+	return context.WithValue(ctx, customerKey{}, customerID)
+}
+
+// CustomerFrom returns the customer set by WithCustomer, or "".
+func CustomerFrom(ctx context.Context) string {
+	id, _ := ctx.Value(customerKey{}).(string)
+	return id
+}
+
 
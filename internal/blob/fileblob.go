@@ -3,24 +3,205 @@ package blob
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ErrOutsideRoots is returned for local paths outside FileBlob's allowed roots.
+var ErrOutsideRoots = errors.New("path is outside the allowed file roots")
+
 // FileBlob implements Reader for local filesystem paths with file:// scheme or plain paths.
-type FileBlob struct{}
+// With Roots set, only files under those directories are served, after resolving
+// symlinks, so a link cannot point out of them. Customers overrides Roots for the
+// customer set with WithCustomer. Without either, any path is served.
+type FileBlob struct {
+	Roots     []string
+	Customers map[string][]string
+}
+
+func (f FileBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return f.open(ctx, uri)
+}
+
+// Size implements Sizer.
+func (f FileBlob) Size(ctx context.Context, uri string) (int64, error) {
+	file, err := f.open(ctx, uri)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Check implements Checker: it fails with ErrOutsideRoots for paths outside the roots.
+// Files that do not exist yet are checked by their closest existing directory.
+func (f FileBlob) Check(ctx context.Context, uri string) error {
+	_, err := f.path(ctx, uri)
+	return err
+}
+
+// Create implements Writer. The content goes to a temporary file next to the target,
+// which Close renames into place, so readers never see a partial file.
+func (f FileBlob) Create(ctx context.Context, uri string) (io.WriteCloser, error) {
+	path, err := f.path(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{ctx: ctx, f: tmp, path: path}, nil
+}
 
-func (f FileBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) { // ctx unused for file
+// Delete implements Deleter.
+func (f FileBlob) Delete(ctx context.Context, uri string) error {
+	path, err := f.path(ctx, uri)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// open opens the file of uri. With roots, a directory on the checked path could be
+// swapped for a symlink before the file is opened, so the path is checked again
+// afterwards and must still name the opened file.
+func (f FileBlob) open(ctx context.Context, uri string) (*os.File, error) {
+	path, err := f.path(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil || len(f.roots(ctx)) == 0 {
+		return file, err
+	}
+	if err := f.sameFile(ctx, uri, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// sameFile fails with ErrOutsideRoots unless uri, checked against the roots again,
+// names the opened file.
+func (f FileBlob) sameFile(ctx context.Context, uri string, file *os.File) error {
+	path, err := f.path(ctx, uri)
+	if err != nil {
+		return err
+	}
+	opened, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	named, err := os.Stat(path)
+	if err != nil || !os.SameFile(opened, named) {
+		return fmt.Errorf("%s: changed while opening: %w", uri, ErrOutsideRoots)
+	}
+	return nil
+}
+
+func (f FileBlob) roots(ctx context.Context) []string {
+	if r, ok := f.Customers[CustomerFrom(ctx)]; ok {
+		return r
+	}
+	return f.Roots
+}
+
+// path returns the local path of uri with symlinks resolved, checked against the roots
+// that apply to ctx's customer.
+func (f FileBlob) path(ctx context.Context, uri string) (string, error) {
+	path, err := localPath(uri)
+	if err != nil {
+		return "", err
+	}
+	roots := f.roots(ctx)
+	if len(roots) == 0 {
+		return path, nil
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range roots {
+		rr, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(rr, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", uri, ErrOutsideRoots)
+}
+
+// resolvePath returns the absolute form of path with symlinks resolved. A path that
+// does not exist is resolved through its closest existing parent.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	parent := filepath.Dir(abs)
+	if parent == abs {
+		return abs, nil
+	}
+	resolved, err = resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(abs)), nil
+}
+
+func localPath(uri string) (string, error) {
 	if uri == "" {
-		return nil, errors.New("empty uri")
+		return "", errors.New("empty uri")
 	}
-	// Support file:// and plain paths
 	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
-		return os.Open(filepath.Clean(u.Path))
+		return filepath.Clean(u.Path), nil
 	}
-	return os.Open(filepath.Clean(uri))
+	return filepath.Clean(uri), nil
+}
+
+type fileWriter struct {
+	ctx  context.Context
+	f    *os.File
+	path string
 }
 
+func (w *fileWriter) Write(p []byte) (int, error) { return w.f.Write(p) }
 
+func (w *fileWriter) Close() error {
+	err := w.ctx.Err()
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
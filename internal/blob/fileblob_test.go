@@ -0,0 +1,151 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileTree lays out a root directory and a directory outside it, with symlinks from
+// the root pointing both ways, and returns their paths.
+func fileTree(t *testing.T) (root, outside string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, outside = filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), outside, filepath.Join(dir, "root2")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, body := range map[string]string{
+		filepath.Join(root, "in.csv"):        "in",
+		filepath.Join(root, "sub", "a.csv"):  "a",
+		filepath.Join(outside, "secret.csv"): "secret",
+		filepath.Join(dir, "root2", "x.csv"): "x",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		filepath.Join(root, "link-out"):  outside,
+		filepath.Join(root, "file-link"): filepath.Join(outside, "secret.csv"),
+		filepath.Join(root, "link-in"):   filepath.Join(root, "sub"),
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, outside
+}
+
+func TestFileBlobRoots(t *testing.T) {
+	root, outside := fileTree(t)
+	dir := filepath.Dir(root)
+	fb := FileBlob{Roots: []string{root}, Customers: map[string][]string{"c2": {outside}}}
+
+	tests := []struct {
+		name     string
+		customer string
+		uri      string
+		// check is Check's error, open Open's; body is read when open is nil
+		check, open error
+		body        string
+	}{
+		{"plain path", "", filepath.Join(root, "in.csv"), nil, nil, "in"},
+		{"file uri", "", "file://" + filepath.Join(root, "sub", "a.csv"), nil, nil, "a"},
+		{"symlink inside root", "", filepath.Join(root, "link-in", "a.csv"), nil, nil, "a"},
+		{"missing file under root", "", filepath.Join(root, "new", "x.csv"), nil, fs.ErrNotExist, ""},
+		{"dot dot traversal", "", root + "/../outside/secret.csv", ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"escaped dot dot in uri", "", "file://" + root + "/%2e%2e/outside/secret.csv", ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"sibling with root as prefix", "", filepath.Join(dir, "root2", "x.csv"), ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"symlinked directory escape", "", filepath.Join(root, "link-out", "secret.csv"), ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"symlinked file escape", "", filepath.Join(root, "file-link"), ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"missing file under escaping symlink", "", filepath.Join(root, "link-out", "new.csv"), ErrOutsideRoots, ErrOutsideRoots, ""},
+		{"customer roots accept", "c2", filepath.Join(outside, "secret.csv"), nil, nil, "secret"},
+		{"customer roots replace default", "c2", filepath.Join(root, "in.csv"), ErrOutsideRoots, ErrOutsideRoots, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithCustomer(context.Background(), tt.customer)
+			if err := fb.Check(ctx, tt.uri); !errors.Is(err, tt.check) {
+				t.Fatalf("Check(%s) = %v, want %v", tt.uri, err, tt.check)
+			}
+			rc, err := fb.Open(ctx, tt.uri)
+			if !errors.Is(err, tt.open) {
+				t.Fatalf("Open(%s) = %v, want %v", tt.uri, err, tt.open)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+			if b, err := io.ReadAll(rc); err != nil || string(b) != tt.body {
+				t.Fatalf("read %q, %v; want %q", b, err, tt.body)
+			}
+		})
+	}
+}
+
+func TestFileBlobWithoutRoots(t *testing.T) {
+	_, outside := fileTree(t)
+	rc, err := FileBlob{}.Open(context.Background(), filepath.Join(outside, "secret.csv"))
+	if err != nil {
+		t.Fatalf("Open without roots: %v", err)
+	}
+	rc.Close()
+}
+
+// TestFileBlobSameFile covers a directory swapped for a symlink between the path check
+// and the open: whether the swap is undone or kept, the opened file is refused.
+func TestFileBlobSameFile(t *testing.T) {
+	root, outside := fileTree(t)
+	fb := FileBlob{Roots: []string{root}}
+	ctx := context.Background()
+	secret, err := os.Open(filepath.Join(outside, "secret.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secret.Close()
+
+	// swapped back: the path names an in-root file again, not the opened one
+	if err := fb.sameFile(ctx, filepath.Join(root, "in.csv"), secret); !errors.Is(err, ErrOutsideRoots) {
+		t.Fatalf("sameFile after swap back = %v, want ErrOutsideRoots", err)
+	}
+	// kept: the path now resolves outside the roots
+	if err := os.Rename(filepath.Join(root, "sub"), filepath.Join(root, "sub.old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.sameFile(ctx, filepath.Join(root, "sub", "secret.csv"), secret); !errors.Is(err, ErrOutsideRoots) {
+		t.Fatalf("sameFile with swap kept = %v, want ErrOutsideRoots", err)
+	}
+
+	in, err := os.Open(filepath.Join(root, "in.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if err := fb.sameFile(ctx, filepath.Join(root, "in.csv"), in); err != nil {
+		t.Fatalf("sameFile of an unchanged path = %v", err)
+	}
+}
+
+func TestFileBlobSize(t *testing.T) {
+	root, _ := fileTree(t)
+	fb := FileBlob{Roots: []string{root}}
+	if n, err := fb.Size(context.Background(), filepath.Join(root, "sub", "a.csv")); err != nil || n != 1 {
+		t.Fatalf("Size = %d, %v; want 1", n, err)
+	}
+	if _, err := fb.Size(context.Background(), filepath.Join(root, "link-out", "secret.csv")); !errors.Is(err, ErrOutsideRoots) {
+		t.Fatalf("Size through escaping symlink = %v, want ErrOutsideRoots", err)
+	}
+}
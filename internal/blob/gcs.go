@@ -0,0 +1,246 @@
+package blob
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsScope is the OAuth scope requested for service account tokens.
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+// GCSConfig holds the credentials for Google Cloud Storage.
+type GCSConfig struct {
+	// CredentialsFile is a service account JSON key file. When empty, requests are
+	// anonymous; the default config falls back to GOOGLE_APPLICATION_CREDENTIALS first.
+	CredentialsFile string `json:"credentials_file"`
+	// Endpoint overrides https://storage.googleapis.com, e.g. http://localhost:4443
+	// for fake-gcs-server.
+	Endpoint string `json:"endpoint"`
+}
+
+// GCSBlob reads gs://bucket/object URIs through the Cloud Storage JSON API. The
+// credentials are taken from Customers for the customer set with WithCustomer, or
+// from Default.
+type GCSBlob struct {
+	Default   GCSConfig
+	Customers map[string]GCSConfig
+	// Client sends the requests; http.DefaultClient when nil.
+	Client *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]gcsToken
+}
+
+type gcsToken struct {
+	value  string
+	expiry time.Time
+}
+
+// Open implements Reader.
+func (b *GCSBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, uri, "alt=media")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Size implements Sizer.
+func (b *GCSBlob) Size(ctx context.Context, uri string) (int64, error) {
+	resp, err := b.do(ctx, uri, "fields=size")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var meta struct {
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(meta.Size, 10, 64)
+}
+
+// config returns the customer's config, or the default one with the key file from
+// GOOGLE_APPLICATION_CREDENTIALS when it names none. Customers with their own config
+// never get the service's ambient credentials.
+func (b *GCSBlob) config(ctx context.Context) GCSConfig {
+	if c, ok := b.Customers[CustomerFrom(ctx)]; ok {
+		return c
+	}
+	c := b.Default
+	c.CredentialsFile = firstNonEmpty(c.CredentialsFile, os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	return c
+}
+
+func (b *GCSBlob) do(ctx context.Context, uri, query string) (*http.Response, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	object := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "gs" || u.Host == "" || object == "" {
+		return nil, errors.New("invalid gcs uri, expected gs://bucket/object: " + uri)
+	}
+	cfg := b.config(ctx)
+	endpoint := strings.TrimSuffix(firstNonEmpty(cfg.Endpoint, "https://storage.googleapis.com"), "/")
+	target := endpoint + "/storage/v1/b/" + url.PathEscape(u.Host) + "/o/" + url.PathEscape(object) + "?" + query
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if file := cfg.CredentialsFile; file != "" {
+		tok, err := b.token(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("gcs credentials %s: %w", file, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, newGCSStatusError(uri, resp)
+	}
+	return resp, nil
+}
+
+func (b *GCSBlob) client() *http.Client {
+	if b.Client != nil {
+		return b.Client
+	}
+	return http.DefaultClient
+}
+
+// token returns a cached access token for the service account in file, exchanging a
+// signed JWT for a new one when it is about to expire.
+func (b *GCSBlob) token(ctx context.Context, file string) (string, error) {
+	b.mu.Lock()
+	t, ok := b.tokens[file]
+	b.mu.Unlock()
+	if ok && time.Until(t.expiry) > time.Minute {
+		return t.value, nil
+	}
+
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var key struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", err
+	}
+	if key.Type != "service_account" {
+		return "", fmt.Errorf("unsupported credentials type %q, expected service_account", key.Type)
+	}
+	tokenURI := firstNonEmpty(key.TokenURI, "https://oauth2.googleapis.com/token")
+	assertion, err := signJWT(key.PrivateKey, map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": gcsScope,
+		"aud":   tokenURI,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := b.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return "", fmt.Errorf("token exchange: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	b.mu.Lock()
+	if b.tokens == nil {
+		b.tokens = map[string]gcsToken{}
+	}
+	b.tokens[file] = gcsToken{value: tr.AccessToken, expiry: time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)}
+	b.mu.Unlock()
+	return tr.AccessToken, nil
+}
+
+// signJWT returns an RS256 JWT with claims, signed with a PEM PKCS#8 or PKCS#1 RSA key.
+func signJWT(privateKeyPEM string, claims map[string]any) (string, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return "", errors.New("private_key is not PEM encoded")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rk, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("private_key is not an RSA key")
+		}
+		key = rk
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+// newGCSStatusError reads the JSON error body of the Cloud Storage API.
+func newGCSStatusError(uri string, resp *http.Response) *StatusError {
+	e := &StatusError{Method: http.MethodGet, URI: uri, StatusCode: resp.StatusCode}
+	var body struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+		e.Code, e.Message = body.Error.Status, body.Error.Message
+	}
+	return e
+}
@@ -0,0 +1,202 @@
+package blob
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeGCS is a Cloud Storage endpoint with an OAuth token endpoint that checks the
+// service account's signed JWT.
+type fakeGCS struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	exchanges atomic.Int32
+	// auth is the Authorization header of the last storage request.
+	auth atomic.Value
+}
+
+func newFakeGCS(t *testing.T) *fakeGCS {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeGCS{key: key}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGCS) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		if err := f.checkAssertion(r); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":"invalid_grant","error_description":"`+err.Error()+`"}`)
+			return
+		}
+		n := f.exchanges.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"access_token": fmt.Sprintf("tok-%d", n), "expires_in": 3600, "token_type": "Bearer"})
+		return
+	}
+	f.auth.Store(r.Header.Get("Authorization"))
+	if r.URL.EscapedPath() != "/storage/v1/b/bucket/o/dir%2Fusers.csv" {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"code":404,"message":"No such object: bucket/missing.csv","status":"NOT_FOUND"}}`)
+		return
+	}
+	switch r.URL.RawQuery {
+	case "alt=media":
+		io.WriteString(w, "id\n1\n")
+	case "fields=size":
+		io.WriteString(w, `{"size":"5"}`)
+	}
+}
+
+func (f *fakeGCS) checkAssertion(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		return errors.New("bad grant_type")
+	}
+	parts := strings.Split(r.PostForm.Get("assertion"), ".")
+	if len(parts) != 3 {
+		return errors.New("malformed jwt")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&f.key.PublicKey, crypto.SHA256, sum[:], sig); err != nil {
+		return err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		Iss   string `json:"iss"`
+		Scope string `json:"scope"`
+		Aud   string `json:"aud"`
+		Iat   int64  `json:"iat"`
+		Exp   int64  `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return err
+	}
+	if claims.Iss != "importer@project.iam.gserviceaccount.com" || claims.Scope != gcsScope || claims.Aud != f.srv.URL+"/token" || claims.Exp <= claims.Iat {
+		return errors.New("bad claims")
+	}
+	return nil
+}
+
+// keyFile writes a service account key file for the fake's token endpoint.
+func (f *fakeGCS) keyFile(t *testing.T, pkcs1 bool) string {
+	return f.keyFileWith(t, f.key, pkcs1)
+}
+
+func (f *fakeGCS) keyFileWith(t *testing.T, key *rsa.PrivateKey, pkcs1 bool) string {
+	t.Helper()
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if !pkcs1 {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	b, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "importer@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(block)),
+		"token_uri":    f.srv.URL + "/token",
+	})
+	p := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestGCSBlobServiceAccount(t *testing.T) {
+	for _, pkcs1 := range []bool{false, true} {
+		f := newFakeGCS(t)
+		b := &GCSBlob{Default: GCSConfig{CredentialsFile: f.keyFile(t, pkcs1), Endpoint: f.srv.URL}}
+		ctx := context.Background()
+		rc, err := b.Open(ctx, "gs://bucket/dir/users.csv")
+		if err != nil {
+			t.Fatalf("Open (pkcs1 %t): %v", pkcs1, err)
+		}
+		got, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(got) != "id\n1\n" || f.auth.Load() != "Bearer tok-1" {
+			t.Fatalf("read %q with Authorization %q", got, f.auth.Load())
+		}
+		if n, err := b.Size(ctx, "gs://bucket/dir/users.csv"); err != nil || n != 5 {
+			t.Fatalf("Size = %d, %v; want 5", n, err)
+		}
+		if n := f.exchanges.Load(); n != 1 {
+			t.Fatalf("%d token exchanges, want 1 with the token cached", n)
+		}
+	}
+}
+
+func TestGCSBlobTokenRejected(t *testing.T) {
+	f := newFakeGCS(t)
+	// a key the token endpoint does not know
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &GCSBlob{Default: GCSConfig{CredentialsFile: f.keyFileWith(t, other, false), Endpoint: f.srv.URL}}
+	if _, err := b.Open(context.Background(), "gs://bucket/dir/users.csv"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Open: err = %v, want the token exchange to fail", err)
+	}
+}
+
+func TestGCSBlobNotFound(t *testing.T) {
+	f := newFakeGCS(t)
+	_, err := (&GCSBlob{Default: GCSConfig{Endpoint: f.srv.URL}}).Open(context.Background(), "gs://bucket/missing.csv")
+	var se *StatusError
+	if !errors.Is(err, fs.ErrNotExist) || !errors.As(err, &se) || se.Code != "NOT_FOUND" {
+		t.Fatalf("Open: err = %v, want NOT_FOUND matching fs.ErrNotExist", err)
+	}
+}
+
+func TestGCSBlobEnvCredentialsOnlyForDefault(t *testing.T) {
+	f := newFakeGCS(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", f.keyFile(t, false))
+	b := &GCSBlob{
+		Default:   GCSConfig{Endpoint: f.srv.URL},
+		Customers: map[string]GCSConfig{"c1": {Endpoint: f.srv.URL}},
+	}
+	for customer, want := range map[string]string{"c1": "", "c2": "Bearer tok-1"} {
+		rc, err := b.Open(WithCustomer(context.Background(), customer), "gs://bucket/dir/users.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if got := f.auth.Load(); got != want {
+			t.Errorf("customer %s: Authorization = %q, want %q", customer, got, want)
+		}
+	}
+}
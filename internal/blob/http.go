@@ -0,0 +1,177 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// HTTPConfig holds the credentials and limits for HTTP(S) downloads.
+type HTTPConfig struct {
+	// Username and Password are sent with basic auth, BearerToken as a bearer token.
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	BearerToken string `json:"bearer_token"`
+	// Host is the only host the credentials are sent to, and required with them.
+	// Pre-signed links carry their own credentials and need none.
+	Host string `json:"host"`
+	// TimeoutSec bounds waiting for the response and each stalled read (DefaultTimeout
+	// when 0). Retries is how often a transient failure is retried (DefaultRetries when
+	// 0, none when negative). MaxBytes refuses larger downloads when set.
+	TimeoutSec int   `json:"timeout_sec"`
+	Retries    int   `json:"retries"`
+	MaxBytes   int64 `json:"max_bytes"`
+}
+
+// HTTPBlob reads https:// (and http://) URLs with a GET request. The credentials are
+// taken from Customers for the customer set with WithCustomer, or from Default.
+// Network errors and 408, 429 and 5xx responses are retried before the content
+// starts streaming.
+type HTTPBlob struct {
+	Default   HTTPConfig
+	Customers map[string]HTTPConfig
+	// Client sends the requests; http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// Open implements Reader.
+func (b *HTTPBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	cfg := b.config(ctx)
+	lim := cfg.limits()
+	resp, cancel, err := b.do(ctx, cfg, http.MethodGet, uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := lim.checkSize(uri, resp.ContentLength); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+	return lim.wrap(uri, &httpBody{ReadCloser: resp.Body, cancel: cancel}, cancel), nil
+}
+
+// Size implements Sizer.
+func (b *HTTPBlob) Size(ctx context.Context, uri string) (int64, error) {
+	resp, cancel, err := b.do(ctx, b.config(ctx), http.MethodHead, uri)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	cancel()
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("size of %s is unknown", uri)
+	}
+	return resp.ContentLength, nil
+}
+
+// Validate refuses credentials without the Host they are meant for.
+func (c HTTPConfig) Validate() error {
+	if (c.Username != "" || c.Password != "" || c.BearerToken != "") && c.Host == "" {
+		return errors.New("http credentials are set without the host to send them to")
+	}
+	return nil
+}
+
+func (b *HTTPBlob) config(ctx context.Context) HTTPConfig {
+	if c, ok := b.Customers[CustomerFrom(ctx)]; ok {
+		return c
+	}
+	return b.Default
+}
+
+func (c HTTPConfig) limits() transferLimits {
+	return newTransferLimits(c.TimeoutSec, c.Retries, c.MaxBytes)
+}
+
+// do sends the request until it gets a 2xx response, retrying transient failures. The
+// returned cancel releases the response once its body is done with.
+func (b *HTTPBlob) do(ctx context.Context, cfg HTTPConfig, method, uri string) (*http.Response, context.CancelFunc, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, nil, errors.New("invalid http uri, expected https://host/path: " + uri)
+	}
+	lim := cfg.limits()
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var resp *http.Response
+	var cancel context.CancelFunc
+	err = lim.retry(ctx, transientHTTP, func() error {
+		rctx, rcancel := context.WithCancel(ctx)
+		req, err := http.NewRequestWithContext(rctx, method, uri, nil)
+		if err != nil {
+			rcancel()
+			return err
+		}
+		if cfg.Host != "" && strings.EqualFold(cfg.Host, u.Hostname()) {
+			switch {
+			case cfg.BearerToken != "":
+				req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+			case cfg.Username != "":
+				req.SetBasicAuth(cfg.Username, cfg.Password)
+			}
+		}
+		timer := time.AfterFunc(lim.timeout, rcancel)
+		r, err := client.Do(req)
+		if !timer.Stop() {
+			if err == nil {
+				r.Body.Close()
+			}
+			rcancel()
+			return fmt.Errorf("%s %s: no response within %s: %w", method, uri, lim.timeout, os.ErrDeadlineExceeded)
+		}
+		if err != nil {
+			rcancel()
+			return err
+		}
+		if r.StatusCode/100 != 2 {
+			defer rcancel()
+			defer r.Body.Close()
+			return &StatusError{Method: method, URI: uri, StatusCode: r.StatusCode}
+		}
+		resp, cancel = r, rcancel
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, cancel, nil
+}
+
+// transientHTTP reports whether a failed request is worth retrying.
+func transientHTTP(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// httpBody releases the request's context when the body is closed.
+type httpBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *httpBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,232 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusSequence answers with the given statuses in turn, then with body, counting calls.
+func statusSequence(calls *atomic.Int32, body string, statuses ...int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		io.WriteString(w, body)
+	}
+}
+
+func TestHTTPBlobRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		retries   int
+		statuses  []int
+		wantCalls int32
+		wantErr   int
+	}{
+		{"success", 1, nil, 1, 0},
+		{"5xx retried", 1, []int{http.StatusServiceUnavailable}, 2, 0},
+		{"429 retried", 1, []int{http.StatusTooManyRequests}, 2, 0},
+		{"retries run out", 1, []int{http.StatusInternalServerError, http.StatusBadGateway}, 2, http.StatusBadGateway},
+		{"no retries", -1, []int{http.StatusServiceUnavailable}, 1, http.StatusServiceUnavailable},
+		{"404 not retried", 1, []int{http.StatusNotFound}, 1, http.StatusNotFound},
+		{"403 not retried", 1, []int{http.StatusForbidden}, 1, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(statusSequence(&calls, "id\n1\n", tt.statuses...))
+			defer srv.Close()
+			b := &HTTPBlob{Default: HTTPConfig{Retries: tt.retries}}
+
+			rc, err := b.Open(context.Background(), srv.URL+"/users.csv")
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d requests, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErr == 0 {
+				if err != nil {
+					t.Fatalf("Open: %v", err)
+				}
+				got, _ := io.ReadAll(rc)
+				rc.Close()
+				if string(got) != "id\n1\n" {
+					t.Fatalf("read %q", got)
+				}
+				return
+			}
+			var se *StatusError
+			if !errors.As(err, &se) || se.StatusCode != tt.wantErr {
+				t.Fatalf("Open: err = %v, want status %d", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPBlobNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := (&HTTPBlob{}).Open(context.Background(), srv.URL+"/missing.csv")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestHTTPBlobMaxBytes(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/chunked" {
+			// no Content-Length: the limit applies while reading
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	b := &HTTPBlob{Default: HTTPConfig{MaxBytes: 50}}
+	ctx := context.Background()
+
+	if _, err := b.Open(ctx, srv.URL+"/sized"); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Open with Content-Length over the limit: err = %v, want ErrTooLarge", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("%d requests, want 1: too large is not retried", calls.Load())
+	}
+	rc, err := b.Open(ctx, srv.URL+"/chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("reading over the limit: err = %v, want ErrTooLarge", err)
+	}
+
+	exact := &HTTPBlob{Default: HTTPConfig{MaxBytes: 100}}
+	rc, err = exact.Open(ctx, srv.URL+"/chunked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if got, err := io.ReadAll(rc); err != nil || len(got) != 100 {
+		t.Fatalf("reading exactly the limit = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestHTTPBlobTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stall-body" {
+			io.WriteString(w, "id\n")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	b := &HTTPBlob{Default: HTTPConfig{TimeoutSec: 1, Retries: -1}}
+	ctx := context.Background()
+
+	start := time.Now()
+	if _, err := b.Open(ctx, srv.URL+"/stall-headers"); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Open: err = %v, want a deadline error", err)
+	}
+	rc, err := b.Open(ctx, srv.URL+"/stall-body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if _, err := io.ReadAll(rc); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("reading a stalled body: err = %v, want a deadline error", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("took %s, want about the 1s timeout twice", d)
+	}
+}
+
+func TestHTTPBlobCredentialsOnlyToHost(t *testing.T) {
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	tests := []struct {
+		name string
+		cfg  HTTPConfig
+		want string
+	}{
+		{"matching host", HTTPConfig{BearerToken: "tok", Host: u.Hostname()}, "Bearer tok"},
+		{"basic auth", HTTPConfig{Username: "u", Password: "p", Host: strings.ToUpper(u.Hostname())}, "Basic dTpw"},
+		{"other host", HTTPConfig{BearerToken: "tok", Host: "exports.partner.example"}, ""},
+		{"no host", HTTPConfig{BearerToken: "tok"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.Store("")
+			rc, err := (&HTTPBlob{Default: tt.cfg}).Open(context.Background(), srv.URL+"/f.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc.Close()
+			if got := auth.Load(); got != tt.want {
+				t.Fatalf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPBlobCustomerCredentials(t *testing.T) {
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	b := &HTTPBlob{
+		Default:   HTTPConfig{BearerToken: "default", Host: u.Hostname()},
+		Customers: map[string]HTTPConfig{"c1": {BearerToken: "c1", Host: u.Hostname()}},
+	}
+	for customer, want := range map[string]string{"c1": "Bearer c1", "c2": "Bearer default"} {
+		rc, err := b.Open(WithCustomer(context.Background(), customer), srv.URL+"/f.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if got := auth.Load(); got != want {
+			t.Errorf("customer %s: Authorization = %q, want %q", customer, got, want)
+		}
+	}
+}
+
+func TestHTTPConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg     HTTPConfig
+		wantErr bool
+	}{
+		{HTTPConfig{}, false},
+		{HTTPConfig{TimeoutSec: 5, MaxBytes: 10}, false},
+		{HTTPConfig{BearerToken: "tok", Host: "exports.partner.example"}, false},
+		{HTTPConfig{BearerToken: "tok"}, true},
+		{HTTPConfig{Username: "u", Password: "p"}, true},
+		{HTTPConfig{Password: "p"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, want error %t", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedScheme is returned for URIs whose scheme has no registered reader.
+var ErrUnsupportedScheme = errors.New("unsupported blob uri scheme")
+
+// Registry dispatches blob URIs to the Reader registered for their scheme, so one
+// service can read file://, s3:// and other jobs side by side. Plain paths count as
+// file://. It implements Reader, Sizer, Writer and Deleter as far as the registered
+// reader for a URI does.
+type Registry struct {
+	schemes map[string]Reader
+	hosts   []hostRule
+}
+
+type hostRule struct {
+	scheme, suffix string
+	r              Reader
+}
+
+func NewRegistry() *Registry { return &Registry{schemes: map[string]Reader{}} }
+
+// Register serves URIs of scheme with r.
+func (g *Registry) Register(scheme string, r Reader) {
+	g.schemes[strings.ToLower(scheme)] = r
+}
+
+// RegisterHost serves URIs of scheme whose host ends with suffix with r, ahead of the
+// scheme's own reader; e.g. https URLs of Azure storage accounts.
+func (g *Registry) RegisterHost(scheme, suffix string, r Reader) {
+	g.hosts = append(g.hosts, hostRule{scheme: strings.ToLower(scheme), suffix: strings.ToLower(suffix), r: r})
+}
+
+// Schemes returns the registered schemes, sorted.
+func (g *Registry) Schemes() []string {
+	out := make([]string, 0, len(g.schemes))
+	for s := range g.schemes {
+		out = append(out, s)
+	}
+	for _, h := range g.hosts {
+		if _, ok := g.schemes[h.scheme]; !ok {
+			out = append(out, h.scheme+"://*"+h.suffix)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Check reports whether uri has a registered reader, wrapping ErrUnsupportedScheme
+// when it does not, and whether that reader accepts it when it is a Checker. Callers
+// use it to refuse jobs before they are enqueued.
+func (g *Registry) Check(ctx context.Context, uri string) error {
+	r, err := g.reader(uri)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(Checker); ok {
+		return c.Check(ctx, uri)
+	}
+	return nil
+}
+
+func (g *Registry) reader(uri string) (Reader, error) {
+	if uri == "" {
+		return nil, errors.New("empty uri")
+	}
+	scheme, host := "file", ""
+	if u, err := url.Parse(uri); err == nil && u.Scheme != "" {
+		scheme, host = strings.ToLower(u.Scheme), strings.ToLower(u.Hostname())
+	}
+	for _, h := range g.hosts {
+		if h.scheme == scheme && strings.HasSuffix(host, h.suffix) {
+			return h.r, nil
+		}
+	}
+	if r, ok := g.schemes[scheme]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w %q in %s (registered: %s)", ErrUnsupportedScheme, scheme, uri, strings.Join(g.Schemes(), ", "))
+}
+
+func (g *Registry) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	r, err := g.reader(uri)
+	if err != nil {
+		return nil, err
+	}
+	return r.Open(ctx, uri)
+}
+
+// Size implements Sizer for URIs whose reader does.
+func (g *Registry) Size(ctx context.Context, uri string) (int64, error) {
+	r, err := g.reader(uri)
+	if err != nil {
+		return 0, err
+	}
+	sz, ok := r.(Sizer)
+	if !ok {
+		return 0, fmt.Errorf("size of %s is unknown", uri)
+	}
+	return sz.Size(ctx, uri)
+}
+
+// Create implements Writer for URIs whose reader does.
+func (g *Registry) Create(ctx context.Context, uri string) (io.WriteCloser, error) {
+	r, err := g.reader(uri)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := r.(Writer)
+	if !ok {
+		return nil, fmt.Errorf("blob backend for %s is read-only", uri)
+	}
+	return w.Create(ctx, uri)
+}
+
+// Delete implements Deleter for URIs whose reader does.
+func (g *Registry) Delete(ctx context.Context, uri string) error {
+	r, err := g.reader(uri)
+	if err != nil {
+		return err
+	}
+	d, ok := r.(Deleter)
+	if !ok {
+		return fmt.Errorf("blob backend for %s cannot delete", uri)
+	}
+	return d.Delete(ctx, uri)
+}
@@ -0,0 +1,341 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures S3Blob. Empty fields fall back to the standard AWS environment
+// variables: AWS_REGION (or AWS_DEFAULT_REGION), AWS_ENDPOINT_URL_S3 (or
+// AWS_ENDPOINT_URL), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type S3Config struct {
+	// Region defaults to us-east-1.
+	Region string `json:"region"`
+	// Endpoint overrides the AWS endpoint, e.g. http://localhost:9000 for MinIO.
+	Endpoint string `json:"endpoint"`
+	// PathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key.
+	// MinIO and most other S3-compatible stores need it.
+	PathStyle       bool   `json:"path_style"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+}
+
+// S3Blob reads and writes s3://bucket/key objects on AWS S3 or an S3-compatible store.
+// Requests are signed with AWS Signature Version 4, or sent unsigned when no access key
+// is configured.
+type S3Blob struct {
+	cfg S3Config
+	// Client sends the requests; http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// NewS3Blob returns an S3Blob for cfg, filling empty fields from the environment.
+func NewS3Blob(cfg S3Config) *S3Blob {
+	cfg.Region = firstNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	cfg.Endpoint = firstNonEmpty(cfg.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	return &S3Blob{cfg: cfg}
+}
+
+// Open implements Reader. The object is streamed; nothing is buffered in memory.
+func (b *S3Blob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, uri, nil, 0, emptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Size implements Sizer.
+func (b *S3Blob) Size(ctx context.Context, uri string) (int64, error) {
+	resp, err := b.do(ctx, http.MethodHead, uri, nil, 0, emptySHA256)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// Create implements Writer. The content is spooled to a temporary file and sent with a
+// single PUT on Close, which limits objects to the 5 GiB S3 allows for one PUT.
+func (b *S3Blob) Create(ctx context.Context, uri string) (io.WriteCloser, error) {
+	if _, _, err := parseS3URI(uri); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &s3Writer{ctx: ctx, b: b, uri: uri, f: tmp, h: sha256.New()}, nil
+}
+
+// Delete implements Deleter.
+func (b *S3Blob) Delete(ctx context.Context, uri string) error {
+	resp, err := b.do(ctx, http.MethodDelete, uri, nil, 0, emptySHA256)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for the object at uri and returns a successful response.
+// Error responses are closed and returned as a *StatusError.
+func (b *S3Blob) do(ctx context.Context, method, uri string, body io.Reader, size int64, payloadSHA256 string) (*http.Response, error) {
+	bucket, key, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.objectURL(bucket, key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	b.sign(req, payloadSHA256, time.Now())
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, newStatusError(method, uri, resp)
+	}
+	return resp, nil
+}
+
+func (b *S3Blob) objectURL(bucket, key string) string {
+	scheme, host := "https", "s3."+b.cfg.Region+".amazonaws.com"
+	if b.cfg.Region == "us-east-1" {
+		host = "s3.amazonaws.com"
+	}
+	if b.cfg.Endpoint != "" {
+		if u, err := url.Parse(b.cfg.Endpoint); err == nil && u.Host != "" {
+			scheme, host = u.Scheme, u.Host
+		}
+	}
+	path := "/" + escapePath(key)
+	if b.cfg.PathStyle {
+		path = "/" + bucket + path
+	} else {
+		host = bucket + "." + host
+	}
+	return scheme + "://" + host + path
+}
+
+// sign adds AWS Signature Version 4 headers to req. Every header already set on req is
+// signed along with host, x-amz-date and x-amz-content-sha256.
+func (b *S3Blob) sign(req *http.Request, payloadSHA256 string, now time.Time) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadSHA256)
+	if b.cfg.AccessKeyID == "" {
+		return
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if b.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL),
+		canonHeaders.String(),
+		signed,
+		payloadSHA256,
+	}, "\n")
+	scope := date + "/" + b.cfg.Region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+b.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, b.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.cfg.AccessKeyID+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+}
+
+type s3Writer struct {
+	ctx context.Context
+	b   *S3Blob
+	uri string
+	f   *os.File
+	h   hash.Hash
+	n   int64
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.h.Write(p[:n])
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *s3Writer) Close() error {
+	defer os.Remove(w.f.Name())
+	defer w.f.Close()
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	resp, err := w.b.do(w.ctx, http.MethodPut, w.uri, w.f, w.n, hex.EncodeToString(w.h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StatusError is an error response from a blob store's HTTP API. Responses with status
+// 404 match fs.ErrNotExist, so a missing object fails its job without retries.
+type StatusError struct {
+	Method     string
+	URI        string
+	StatusCode int
+	// Code and Message come from the store's error body when it has one.
+	Code    string
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URI, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == fs.ErrNotExist && e.StatusCode == http.StatusNotFound
+}
+
+// newStatusError reads the XML error body S3 and Azure send with failed requests.
+func newStatusError(method, uri string, resp *http.Response) *StatusError {
+	e := &StatusError{Method: method, URI: uri, StatusCode: resp.StatusCode}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+		e.Code, e.Message = body.Code, body.Message
+	}
+	return e
+}
+
+func parseS3URI(uri string) (bucket, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "s3" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return "", "", errors.New("invalid s3 uri, expected s3://bucket/key: " + uri)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// escapePath percent-encodes an object key for a request path, as S3 Signature Version 4
+// and Azure Shared Key expect: every byte except unreserved characters and '/'.
+func escapePath(key string) string {
+	return uriEncode(key, false)
+}
+
+// canonicalQuery is the query string of u as Signature Version 4 signs it: names and
+// values percent-encoded like paths but including '/', sorted by name, then value.
+// Unlike url.Values.Encode, a space becomes %20, not '+'.
+func canonicalQuery(u *url.URL) string {
+	var pairs [][2]string
+	for name, values := range u.Query() {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{uriEncode(name, true), uriEncode(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte of s except unreserved characters and, unless
+// encodeSlash is set, '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || c == '/' && !encodeSlash {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+// emptySHA256 is the hex SHA-256 of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
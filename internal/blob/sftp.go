@@ -0,0 +1,241 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTPConfig holds the SSH credentials and limits for SFTP servers.
+type SFTPConfig struct {
+	// User logs in unless the URI names one. It authenticates with the private key in
+	// PrivateKeyFile (decrypted with Passphrase when set) and/or Password.
+	User           string `json:"user"`
+	Password       string `json:"password"`
+	PrivateKeyFile string `json:"private_key_file"`
+	Passphrase     string `json:"passphrase"`
+	// KnownHostsFile lists the accepted server host keys; ~/.ssh/known_hosts when
+	// empty. InsecureIgnoreHostKey skips the check, for local test servers only.
+	KnownHostsFile        string `json:"known_hosts_file"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key"`
+	// TimeoutSec, Retries and MaxBytes work as in HTTPConfig; TimeoutSec also bounds
+	// connecting and the SSH handshake.
+	TimeoutSec int   `json:"timeout_sec"`
+	Retries    int   `json:"retries"`
+	MaxBytes   int64 `json:"max_bytes"`
+}
+
+// SFTPBlob reads sftp://[user@]host[:port]/path URIs, one SSH connection per blob.
+// The credentials are taken from Customers for the customer set with WithCustomer,
+// or from Default. Connection failures are retried; host key mismatches and
+// authentication failures are not.
+type SFTPBlob struct {
+	Default   SFTPConfig
+	Customers map[string]SFTPConfig
+}
+
+// Open implements Reader.
+func (b *SFTPBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	cfg := b.config(ctx)
+	lim := cfg.limits()
+	var conn *sftpConn
+	var f *sftp.File
+	err := lim.retry(ctx, transientSFTP, func() error {
+		c, path, err := b.dial(ctx, cfg, uri)
+		if err != nil {
+			return err
+		}
+		file, err := c.client.Open(path)
+		if err != nil {
+			c.Close()
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+		conn, f = c, file
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil {
+		if err := lim.checkSize(uri, fi.Size()); err != nil {
+			f.Close()
+			conn.Close()
+			return nil, err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return lim.wrap(uri, &sftpFile{File: f, conn: conn, stop: stop}, func() { conn.Close() }), nil
+}
+
+// Size implements Sizer.
+func (b *SFTPBlob) Size(ctx context.Context, uri string) (int64, error) {
+	cfg := b.config(ctx)
+	var size int64
+	err := cfg.limits().retry(ctx, transientSFTP, func() error {
+		c, path, err := b.dial(ctx, cfg, uri)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		fi, err := c.client.Stat(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+		size = fi.Size()
+		return nil
+	})
+	return size, err
+}
+
+func (b *SFTPBlob) config(ctx context.Context) SFTPConfig {
+	if c, ok := b.Customers[CustomerFrom(ctx)]; ok {
+		return c
+	}
+	return b.Default
+}
+
+func (c SFTPConfig) limits() transferLimits {
+	return newTransferLimits(c.TimeoutSec, c.Retries, c.MaxBytes)
+}
+
+// dial connects and logs in to the server of uri, returning the file's path on it.
+func (b *SFTPBlob) dial(ctx context.Context, cfg SFTPConfig, uri string) (*sftpConn, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme != "sftp" || u.Host == "" || u.Path == "" || u.Path == "/" {
+		return nil, "", errors.New("invalid sftp uri, expected sftp://host/path: " + uri)
+	}
+	clientCfg, err := cfg.clientConfig(u.User.Username())
+	if err != nil {
+		return nil, "", err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	timeout := cfg.limits().timeout
+	d := net.Dialer{Timeout: timeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	nc.SetDeadline(time.Now().Add(timeout))
+	sc, chans, reqs, err := ssh.NewClientConn(nc, addr, clientCfg)
+	if err != nil {
+		nc.Close()
+		return nil, "", fmt.Errorf("sftp %s: %w", addr, err)
+	}
+	nc.SetDeadline(time.Time{})
+	sshClient := ssh.NewClient(sc, chans, reqs)
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, "", fmt.Errorf("sftp %s: %w", addr, err)
+	}
+	return &sftpConn{ssh: sshClient, client: client}, u.Path, nil
+}
+
+func (c SFTPConfig) clientConfig(uriUser string) (*ssh.ClientConfig, error) {
+	hostKey, err := c.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	var auth []ssh.AuthMethod
+	if c.PrivateKeyFile != "" {
+		pem, err := os.ReadFile(c.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("sftp private key: %w", err)
+		}
+		var signer ssh.Signer
+		if c.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(c.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sftp private key %s: %w", c.PrivateKeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("no sftp credentials configured, set private_key_file or password")
+	}
+	return &ssh.ClientConfig{
+		User:            firstNonEmpty(uriUser, c.User),
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         c.limits().timeout,
+	}, nil
+}
+
+func (c SFTPConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if c.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := c.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("sftp known hosts: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	cb, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("sftp known hosts: %w", err)
+	}
+	return cb, nil
+}
+
+// transientSFTP reports whether a failed connection is worth retrying: network errors
+// are, a rejected host key or login is not.
+func transientSFTP(err error) bool {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+type sftpConn struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+}
+
+// Close drops the SSH connection first, which ends the SFTP session without waiting on
+// the server.
+func (c *sftpConn) Close() error {
+	err := c.ssh.Close()
+	c.client.Close()
+	return err
+}
+
+// sftpFile closes the connection with the file.
+type sftpFile struct {
+	*sftp.File
+	conn *sftpConn
+	stop func() bool
+}
+
+func (f *sftpFile) Close() error {
+	f.stop()
+	err := f.File.Close()
+	f.conn.Close()
+	return err
+}
@@ -0,0 +1,213 @@
+package blob
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpServer is an in-process SFTP server serving the local file system to user "u"
+// with password "pw".
+type sftpServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+	// accepts counts TCP connections; drop closes that many of the first ones
+	// unanswered, and silent leaves all of them unanswered.
+	accepts atomic.Int32
+	drop    atomic.Int32
+	silent  atomic.Bool
+}
+
+func newSFTPServer(t *testing.T) *sftpServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{PasswordCallback: func(c ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+		if c.User() == "u" && string(pw) == "pw" {
+			return nil, nil
+		}
+		return nil, errors.New("denied")
+	}}
+	cfg.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &sftpServer{addr: l.Addr().String(), hostKey: signer.PublicKey()}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			n := s.accepts.Add(1)
+			if s.silent.Load() {
+				t.Cleanup(func() { nc.Close() })
+				continue
+			}
+			if n <= s.drop.Load() {
+				nc.Close()
+				continue
+			}
+			go s.serve(nc, cfg)
+		}
+	}()
+	return s
+}
+
+func (s *sftpServer) serve(nc net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		ch, reqs, err := nch.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for r := range reqs {
+				r.Reply(r.Type == "subsystem", nil)
+			}
+		}()
+		srv, err := sftp.NewServer(ch)
+		if err != nil {
+			return
+		}
+		go srv.Serve()
+	}
+}
+
+// client returns an SFTPBlob trusting the server's host key.
+func (s *sftpServer) client(t *testing.T) *SFTPBlob {
+	t.Helper()
+	kh := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(kh, []byte(knownhosts.Line([]string{s.addr}, s.hostKey)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &SFTPBlob{Default: SFTPConfig{User: "u", Password: "pw", KnownHostsFile: kh, Retries: -1}}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSFTPBlobOpenAndSize(t *testing.T) {
+	s := newSFTPServer(t)
+	b := s.client(t)
+	ctx := context.Background()
+	uri := "sftp://" + s.addr + writeTemp(t, "id,name\n1,x\n")
+
+	rc, err := b.Open(ctx, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != "id,name\n1,x\n" {
+		t.Fatalf("read %q, %v", got, err)
+	}
+	if n, err := b.Size(ctx, uri); err != nil || n != 12 {
+		t.Fatalf("Size = %d, %v; want 12", n, err)
+	}
+	if _, err := b.Open(ctx, "sftp://"+s.addr+"/no/such/file.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open of a missing file: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestSFTPBlobMaxBytes(t *testing.T) {
+	s := newSFTPServer(t)
+	b := s.client(t)
+	b.Default.MaxBytes = 5
+	if _, err := b.Open(context.Background(), "sftp://"+s.addr+writeTemp(t, "id,name\n1,x\n")); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Open: err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestSFTPBlobRetries(t *testing.T) {
+	path := writeTemp(t, "id\n")
+	t.Run("dropped connection retried", func(t *testing.T) {
+		s := newSFTPServer(t)
+		s.drop.Store(1)
+		b := s.client(t)
+		b.Default.Retries = 1
+		rc, err := b.Open(context.Background(), "sftp://"+s.addr+path)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		rc.Close()
+		if n := s.accepts.Load(); n != 2 {
+			t.Fatalf("%d connections, want 2", n)
+		}
+	})
+	t.Run("login failure not retried", func(t *testing.T) {
+		s := newSFTPServer(t)
+		b := s.client(t)
+		b.Default.Retries, b.Default.Password = 3, "wrong"
+		if _, err := b.Open(context.Background(), "sftp://"+s.addr+path); err == nil {
+			t.Fatal("Open with a wrong password succeeded")
+		}
+		if n := s.accepts.Load(); n != 1 {
+			t.Fatalf("%d connections, want 1", n)
+		}
+	})
+	t.Run("unknown host key not retried", func(t *testing.T) {
+		s := newSFTPServer(t)
+		other := newSFTPServer(t)
+		b := other.client(t)
+		b.Default.Retries = 3
+		// other's known_hosts line, listing the wrong key for s's address
+		b.Default.KnownHostsFile = filepath.Join(t.TempDir(), "known_hosts")
+		os.WriteFile(b.Default.KnownHostsFile, []byte(knownhosts.Line([]string{s.addr}, other.hostKey)+"\n"), 0o644)
+		_, err := b.Open(context.Background(), "sftp://"+s.addr+path)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			t.Fatalf("Open: err = %v, want a host key mismatch", err)
+		}
+		if n := s.accepts.Load(); n != 1 {
+			t.Fatalf("%d connections, want 1", n)
+		}
+	})
+}
+
+func TestSFTPBlobHandshakeTimeout(t *testing.T) {
+	s := newSFTPServer(t)
+	s.silent.Store(true)
+	b := s.client(t)
+	b.Default.TimeoutSec = 1
+	start := time.Now()
+	_, err := b.Open(context.Background(), "sftp://"+s.addr+"/data/users.csv")
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("Open: err = %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("took %s, want about the 1s timeout", d)
+	}
+}
@@ -0,0 +1,96 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Defaults for the remote readers' TimeoutSec and Retries when they are zero.
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetries = 3
+)
+
+// ErrTooLarge is returned for blobs larger than a reader's MaxBytes.
+var ErrTooLarge = errors.New("blob exceeds the size limit")
+
+// transferLimits are the settings the remote readers share.
+type transferLimits struct {
+	timeout  time.Duration
+	retries  int
+	maxBytes int64
+}
+
+func newTransferLimits(timeoutSec, retries int, maxBytes int64) transferLimits {
+	l := transferLimits{timeout: time.Duration(timeoutSec) * time.Second, retries: retries, maxBytes: maxBytes}
+	if l.timeout <= 0 {
+		l.timeout = DefaultTimeout
+	}
+	if l.retries == 0 {
+		l.retries = DefaultRetries
+	}
+	return l
+}
+
+// checkSize fails when a blob of size bytes is over the limit; unknown sizes are < 0.
+func (l transferLimits) checkSize(uri string, size int64) error {
+	if l.maxBytes > 0 && size > l.maxBytes {
+		return fmt.Errorf("%s is %d bytes, over %d: %w", uri, size, l.maxBytes, ErrTooLarge)
+	}
+	return nil
+}
+
+// retry calls fn until it succeeds, returns an error transient rejects, or the
+// attempts run out, backing off exponentially from half a second in between.
+func (l transferLimits) retry(ctx context.Context, transient func(error) bool, fn func() error) error {
+	delay := 500 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= l.retries || !transient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// wrap applies the read timeout and size limit to a blob's content; abort must unblock
+// a stalled Read.
+func (l transferLimits) wrap(uri string, rc io.ReadCloser, abort func()) io.ReadCloser {
+	return &limitedReader{rc: rc, uri: uri, left: l.maxBytes, limited: l.maxBytes > 0, timeout: l.timeout, abort: abort}
+}
+
+// limitedReader fails a Read that makes no progress for timeout, and fails the
+// stream with ErrTooLarge once more than the limit was read.
+type limitedReader struct {
+	rc      io.ReadCloser
+	uri     string
+	left    int64
+	limited bool
+	timeout time.Duration
+	abort   func()
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	t := time.AfterFunc(r.timeout, r.abort)
+	n, err := r.rc.Read(p)
+	if !t.Stop() && err != nil {
+		err = fmt.Errorf("reading %s stalled for %s: %w", r.uri, r.timeout, os.ErrDeadlineExceeded)
+	}
+	if r.limited {
+		if r.left -= int64(n); r.left < 0 {
+			return n, fmt.Errorf("%s: %w", r.uri, ErrTooLarge)
+		}
+	}
+	return n, err
+}
+
+func (r *limitedReader) Close() error { return r.rc.Close() }
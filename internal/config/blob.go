@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/user/importer/internal/blob"
+)
+
+// DefaultBlobSchemes are the blob URI schemes served when none are configured.
+var DefaultBlobSchemes = []string{"file"}
+
+// BlobRegistry returns a registry serving the configured BlobSchemes, with the
+// backends' settings from the config and the customers' own credentials from cust.
+func (c AppConfig) BlobRegistry(cust CustomerDBMap) (*blob.Registry, error) {
+	reg := blob.NewRegistry()
+	for _, scheme := range c.BlobSchemes {
+		switch strings.ToLower(strings.TrimSpace(scheme)) {
+		case "file":
+			reg.Register("file", c.fileBlob(cust))
+		case "s3":
+			reg.Register("s3", blob.NewS3Blob(c.S3))
+		case "az", "azure":
+			az := &blob.AzureBlob{Default: c.Azure, Customers: cust.AzureCredentials()}
+			reg.Register("az", az)
+			reg.RegisterHost("https", ".blob.core.windows.net", az)
+		case "gs", "gcs":
+			reg.Register("gs", &blob.GCSBlob{Default: c.GCS, Customers: cust.GCSCredentials()})
+		case "https", "http":
+			reg.Register(strings.ToLower(strings.TrimSpace(scheme)), &blob.HTTPBlob{Default: c.HTTP, Customers: cust.HTTPCredentials()})
+		case "sftp":
+			reg.Register("sftp", &blob.SFTPBlob{Default: c.SFTP, Customers: cust.SFTPCredentials()})
+		default:
+			return nil, fmt.Errorf("unknown blob scheme %q in blob_schemes (available: file, s3, az, gs, https, http, sftp)", scheme)
+		}
+	}
+	return reg, nil
+}
+
+// fileBlob sandboxes file:// blobs to FileRoots, or the working directory when none are
+// set, and to the customers' own roots, adding the upload staging directory to each so
+// staged uploads stay readable.
+func (c AppConfig) fileBlob(cust CustomerDBMap) blob.FileBlob {
+	var staging []string
+	if u, err := url.Parse(c.UploadStagingURI); err == nil && c.UploadStagingURI != "" {
+		switch u.Scheme {
+		case "":
+			staging = []string{c.UploadStagingURI}
+		case "file":
+			staging = []string{u.Path}
+		}
+	}
+	roots := c.FileRoots
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	f := blob.FileBlob{Roots: append(append([]string(nil), roots...), staging...), Customers: map[string][]string{}}
+	for id, r := range cust.FileRoots() {
+		f.Customers[id] = append(append([]string(nil), r...), staging...)
+	}
+	return f
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+
+	"github.com/user/importer/internal/blob"
 )
 
 // AppConfig holds global configuration loaded from environment variables.
@@ -21,10 +24,126 @@ type AppConfig struct {
 	WorkerConcurrency int
 	// Number of records to parse in a batch, default 0 means no batching
 	ParseBatchSize int
+	// Apply pending app DB migrations on startup; when false run `cli migrate` as a separate step
+	AutoMigrate bool
+	// Attempts a job gets before it is moved to the dead state
+	MaxAttempts int
+	// Delay before the first retry of a failed job; doubles with each further attempt
+	RetryBaseDelaySec int
+	// Lease a worker holds on a running job; renewed by heartbeat, reclaimed when it expires
+	LeaseTTLSec int
+	// Cap on a customer's concurrently running jobs across all workers, 0 means no cap
+	MaxRunningPerCustomer int
+	// Refuse jobs whose blob content was already imported successfully for the customer and product
+	DedupContent bool
+	// Blob location uploaded files are staged at before their import, a directory or URI prefix
+	UploadStagingURI string
+	// Largest file accepted by the upload endpoints, in bytes
+	MaxUploadBytes int
+	// S3 settings for s3:// blobs; empty fields fall back to the AWS_* environment variables
+	S3 blob.S3Config
+	// Default Azure, GCS, HTTP and SFTP credentials, for customers without their own in the customer map
+	Azure blob.AzureConfig
+	GCS   blob.GCSConfig
+	HTTP  blob.HTTPConfig
+	SFTP  blob.SFTPConfig
+	// URI schemes jobs may read from, each served by its backend (file, s3, az, gs, https, http, sftp)
+	BlobSchemes []string
+	// Directories file:// blobs may be read from; the working directory when empty.
+	// The upload staging directory is always allowed.
+	FileRoots []string
+}
+
+// DefaultMaxUploadBytes is the upload size limit when none is configured, 1 GiB.
+const DefaultMaxUploadBytes = 1 << 30
+
+// CustomerDBMap is a mapping from customerId to the database target for that customer.
+type CustomerDBMap map[string]CustomerTarget
+
+// CustomerTarget locates a customer's tables: a Postgres DSN and an optional schema
+// within that database. Customers sharing a database are separated by schema.
+// Azure, GCS, HTTP and SFTP hold the customer's own blob storage credentials, if any.
+type CustomerTarget struct {
+	DSN    string            `json:"dsn"`
+	Schema string            `json:"schema,omitempty"`
+	Azure  *blob.AzureConfig `json:"azure,omitempty"`
+	GCS    *blob.GCSConfig   `json:"gcs,omitempty"`
+	HTTP   *blob.HTTPConfig  `json:"http,omitempty"`
+	SFTP   *blob.SFTPConfig  `json:"sftp,omitempty"`
+	// FileRoots replaces the configured file_roots for the customer's file:// blobs.
+	FileRoots []string `json:"file_roots,omitempty"`
+}
+
+// AzureCredentials returns the customers' own Azure credentials, keyed by customer id.
+func (m CustomerDBMap) AzureCredentials() map[string]blob.AzureConfig {
+	out := map[string]blob.AzureConfig{}
+	for id, t := range m {
+		if t.Azure != nil {
+			out[id] = *t.Azure
+		}
+	}
+	return out
+}
+
+// GCSCredentials returns the customers' own GCS credentials, keyed by customer id.
+func (m CustomerDBMap) GCSCredentials() map[string]blob.GCSConfig {
+	out := map[string]blob.GCSConfig{}
+	for id, t := range m {
+		if t.GCS != nil {
+			out[id] = *t.GCS
+		}
+	}
+	return out
+}
+
+// HTTPCredentials returns the customers' own HTTP credentials, keyed by customer id.
+func (m CustomerDBMap) HTTPCredentials() map[string]blob.HTTPConfig {
+	out := map[string]blob.HTTPConfig{}
+	for id, t := range m {
+		if t.HTTP != nil {
+			out[id] = *t.HTTP
+		}
+	}
+	return out
+}
+
+// SFTPCredentials returns the customers' own SFTP credentials, keyed by customer id.
+func (m CustomerDBMap) SFTPCredentials() map[string]blob.SFTPConfig {
+	out := map[string]blob.SFTPConfig{}
+	for id, t := range m {
+		if t.SFTP != nil {
+			out[id] = *t.SFTP
+		}
+	}
+	return out
+}
+
+// FileRoots returns the customers' own file roots, keyed by customer id.
+func (m CustomerDBMap) FileRoots() map[string][]string {
+	out := map[string][]string{}
+	for id, t := range m {
+		if len(t.FileRoots) > 0 {
+			out[id] = t.FileRoots
+		}
+	}
+	return out
 }
 
-// CustomerDBMap is a mapping from customerId to DSN string.
-type CustomerDBMap map[string]string
+// UnmarshalJSON accepts either a plain DSN string or an object with dsn and schema.
+func (t *CustomerTarget) UnmarshalJSON(b []byte) error {
+	var dsn string
+	if err := json.Unmarshal(b, &dsn); err == nil {
+		*t = CustomerTarget{DSN: dsn}
+		return nil
+	}
+	type plain CustomerTarget
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*t = CustomerTarget(p)
+	return nil
+}
 
 // LoadConfig reads configuration from environment variables.
 func LoadConfig() (AppConfig, error) {
@@ -34,6 +153,7 @@ func LoadConfig() (AppConfig, error) {
 		GRPCAddr:        valueOrDefault(os.Getenv("GRPC_ADDR"), ":9090"),
 		CustomerMapPath: valueOrDefault(os.Getenv("CUSTOMER_MAP_PATH"), "customer_map.json"),
 		ParseBatchSize:  0,
+		AutoMigrate:     os.Getenv("APP_DB_AUTO_MIGRATE") != "false",
 	}
 
 	if wc := os.Getenv("WORKER_CONCURRENCY"); wc != "" {
@@ -57,14 +177,29 @@ func LoadConfig() (AppConfig, error) {
 		}
 	}
 
+	c.MaxAttempts = envInt("MAX_ATTEMPTS", 3)
+	c.RetryBaseDelaySec = envInt("RETRY_BASE_DELAY_SEC", 30)
+	c.LeaseTTLSec = envInt("LEASE_TTL_SEC", 60)
+	c.MaxRunningPerCustomer = envInt("MAX_RUNNING_PER_CUSTOMER", 0)
+	c.DedupContent = os.Getenv("DEDUP_CONTENT") == "true"
+	c.UploadStagingURI = valueOrDefault(os.Getenv("UPLOAD_STAGING_URI"), "uploads")
+	c.MaxUploadBytes = envInt("MAX_UPLOAD_BYTES", DefaultMaxUploadBytes)
+	c.BlobSchemes = DefaultBlobSchemes
+	if v := os.Getenv("BLOB_SCHEMES"); v != "" {
+		c.BlobSchemes = strings.Split(v, ",")
+	}
+	if v := os.Getenv("FILE_ROOTS"); v != "" {
+		c.FileRoots = strings.Split(v, ",")
+	}
+
 	if c.AppPostgresDSN == "" {
 		return AppConfig{}, errors.New("APP_DB_DSN is required")
 	}
 	return c, nil
 }
 
-// LoadCustomerMap loads the map of customerId to DSN from a JSON file.
-// File format: { "customer1": "postgres://...", "customer2": "postgres://..." }
+// LoadCustomerMap loads the map of customerId to database target from a JSON file.
+// File format: { "customer1": "postgres://...", "customer2": { "dsn": "postgres://...", "schema": "customer2", "azure": {...}, "gcs": {...}, "http": {...}, "sftp": {...}, "file_roots": [...] } }
 func LoadCustomerMap(path string) (CustomerDBMap, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -74,14 +209,28 @@ func LoadCustomerMap(path string) (CustomerDBMap, error) {
 	if err := json.Unmarshal(b, &m); err != nil {
 		return nil, err
 	}
+	for id, t := range m {
+		if t.HTTP != nil {
+			if err := t.HTTP.Validate(); err != nil {
+				return nil, fmt.Errorf("http config of customer %s: %w", id, err)
+			}
+		}
+	}
 	return m, nil
 }
 
+// envInt parses a positive integer environment variable, returning def when unset or invalid.
+func envInt(name string, def int) int {
+	var n int
+	if _, err := fmt.Sscanf(os.Getenv(name), "%d", &n); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
 func valueOrDefault(value, def string) string {
 	if value == "" {
 		return def
 	}
 	return value
 }
-
-
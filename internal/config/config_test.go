@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestHTTPCredentialsNeedHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		http    string
+		wantErr bool
+	}{
+		{"no credentials", `{"timeout_sec": 10}`, false},
+		{"credentials with host", `{"bearer_token": "tok", "host": "exports.partner.example"}`, false},
+		{"token without host", `{"bearer_token": "tok"}`, true},
+		{"basic auth without host", `{"username": "u", "password": "p"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFromJSON(writeFile(t, `{"default": {"app_db_dsn": "postgres://x", "http": `+tt.http+`}}`), "default")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadFromJSON: err = %v, want error %t", err, tt.wantErr)
+			}
+			_, err = LoadCustomerMap(writeFile(t, `{"c1": "postgres://x", "c2": {"dsn": "postgres://y", "http": `+tt.http+`}}`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadCustomerMap: err = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,15 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"os"
+
+	"github.com/user/importer/internal/blob"
 )
 
 type jsonEnv struct {
-	AppPostgresDSN    string `json:"app_db_dsn"`
-	RESTAddr          string `json:"rest_addr"`
-	GRPCAddr          string `json:"grpc_addr"`
-	CustomerMapPath   string `json:"customer_map_path"`
-	WorkerConcurrency int    `json:"worker_concurrency"`
-	ParseBatchSize    int    `json:"parse_batch_size"`
+	AppPostgresDSN        string            `json:"app_db_dsn"`
+	RESTAddr              string            `json:"rest_addr"`
+	GRPCAddr              string            `json:"grpc_addr"`
+	CustomerMapPath       string            `json:"customer_map_path"`
+	WorkerConcurrency     int               `json:"worker_concurrency"`
+	ParseBatchSize        int               `json:"parse_batch_size"`
+	AutoMigrate           *bool             `json:"auto_migrate"`
+	MaxAttempts           int               `json:"max_attempts"`
+	RetryBaseDelaySec     int               `json:"retry_base_delay_sec"`
+	LeaseTTLSec           int               `json:"lease_ttl_sec"`
+	MaxRunningPerCustomer int               `json:"max_running_per_customer"`
+	DedupContent          *bool             `json:"dedup_content"`
+	UploadStagingURI      string            `json:"upload_staging_uri"`
+	MaxUploadBytes        int               `json:"max_upload_bytes"`
+	S3                    *blob.S3Config    `json:"s3"`
+	Azure                 *blob.AzureConfig `json:"azure"`
+	GCS                   *blob.GCSConfig   `json:"gcs"`
+	HTTP                  *blob.HTTPConfig  `json:"http"`
+	SFTP                  *blob.SFTPConfig  `json:"sftp"`
+	BlobSchemes           []string          `json:"blob_schemes"`
+	FileRoots             []string          `json:"file_roots"`
 }
 
 // LoadFromJSON loads configuration from a JSON file with environment sections.
@@ -54,23 +71,56 @@ func mergeJSONEnvs(envs map[string]jsonEnv, env string) (AppConfig, error) {
 	d := envs["default"]
 	sel := envs[env]
 	out := jsonEnv{
-		AppPostgresDSN:    pickString(sel.AppPostgresDSN, d.AppPostgresDSN),
-		RESTAddr:          pickString(sel.RESTAddr, d.RESTAddr, ":8080"),
-		GRPCAddr:          pickString(sel.GRPCAddr, d.GRPCAddr, ":9090"),
-		CustomerMapPath:   pickString(sel.CustomerMapPath, d.CustomerMapPath, "customer_map.json"),
-		WorkerConcurrency: pickInt(sel.WorkerConcurrency, d.WorkerConcurrency, 4),
-		ParseBatchSize:  pickInt(sel.ParseBatchSize, d.ParseBatchSize, 0),
+		AppPostgresDSN:        pickString(sel.AppPostgresDSN, d.AppPostgresDSN),
+		RESTAddr:              pickString(sel.RESTAddr, d.RESTAddr, ":8080"),
+		GRPCAddr:              pickString(sel.GRPCAddr, d.GRPCAddr, ":9090"),
+		CustomerMapPath:       pickString(sel.CustomerMapPath, d.CustomerMapPath, "customer_map.json"),
+		WorkerConcurrency:     pickInt(sel.WorkerConcurrency, d.WorkerConcurrency, 4),
+		ParseBatchSize:        pickInt(sel.ParseBatchSize, d.ParseBatchSize, 0),
+		AutoMigrate:           pickBool(sel.AutoMigrate, d.AutoMigrate, true),
+		MaxAttempts:           pickInt(sel.MaxAttempts, d.MaxAttempts, 3),
+		RetryBaseDelaySec:     pickInt(sel.RetryBaseDelaySec, d.RetryBaseDelaySec, 30),
+		LeaseTTLSec:           pickInt(sel.LeaseTTLSec, d.LeaseTTLSec, 60),
+		MaxRunningPerCustomer: pickInt(sel.MaxRunningPerCustomer, d.MaxRunningPerCustomer, 0),
+		DedupContent:          pickBool(sel.DedupContent, d.DedupContent, false),
+		UploadStagingURI:      pickString(sel.UploadStagingURI, d.UploadStagingURI, "uploads"),
+		MaxUploadBytes:        pickInt(sel.MaxUploadBytes, d.MaxUploadBytes, DefaultMaxUploadBytes),
+		S3:                    pickSection(sel.S3, d.S3),
+		Azure:                 pickSection(sel.Azure, d.Azure),
+		GCS:                   pickSection(sel.GCS, d.GCS),
+		HTTP:                  pickSection(sel.HTTP, d.HTTP),
+		SFTP:                  pickSection(sel.SFTP, d.SFTP),
+		BlobSchemes:           pickStrings(sel.BlobSchemes, d.BlobSchemes, DefaultBlobSchemes),
+		FileRoots:             pickStrings(sel.FileRoots, d.FileRoots),
 	}
 	if out.AppPostgresDSN == "" {
 		return AppConfig{}, fmt.Errorf("app_db_dsn is required in JSON for env '%s'", env)
 	}
+	if err := out.HTTP.Validate(); err != nil {
+		return AppConfig{}, fmt.Errorf("http config for env '%s': %w", env, err)
+	}
 	return AppConfig{
-		AppPostgresDSN:    out.AppPostgresDSN,
-		RESTAddr:          out.RESTAddr,
-		GRPCAddr:          out.GRPCAddr,
-		CustomerMapPath:   out.CustomerMapPath,
-		WorkerConcurrency: out.WorkerConcurrency,
-		ParseBatchSize:    out.ParseBatchSize,
+		AppPostgresDSN:        out.AppPostgresDSN,
+		RESTAddr:              out.RESTAddr,
+		GRPCAddr:              out.GRPCAddr,
+		CustomerMapPath:       out.CustomerMapPath,
+		WorkerConcurrency:     out.WorkerConcurrency,
+		ParseBatchSize:        out.ParseBatchSize,
+		AutoMigrate:           *out.AutoMigrate,
+		MaxAttempts:           out.MaxAttempts,
+		RetryBaseDelaySec:     out.RetryBaseDelaySec,
+		LeaseTTLSec:           out.LeaseTTLSec,
+		MaxRunningPerCustomer: out.MaxRunningPerCustomer,
+		DedupContent:          *out.DedupContent,
+		UploadStagingURI:      out.UploadStagingURI,
+		MaxUploadBytes:        out.MaxUploadBytes,
+		S3:                    *out.S3,
+		Azure:                 *out.Azure,
+		GCS:                   *out.GCS,
+		HTTP:                  *out.HTTP,
+		SFTP:                  *out.SFTP,
+		BlobSchemes:           out.BlobSchemes,
+		FileRoots:             out.FileRoots,
 	}, nil
 }
 
@@ -83,6 +133,38 @@ func pickString(values ...string) string {
 	return ""
 }
 
+// pickBool returns the first value that is set, or def.
+func pickBool(sel, d *bool, def bool) *bool {
+	if sel != nil {
+		return sel
+	}
+	if d != nil {
+		return d
+	}
+	return &def
+}
+
+// pickStrings returns the first non-empty list.
+func pickStrings(values ...[]string) []string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return nil
+}
+
+// pickSection returns the environment's section, or the default one; sections are not merged.
+func pickSection[T any](sel, d *T) *T {
+	if sel != nil {
+		return sel
+	}
+	if d != nil {
+		return d
+	}
+	return new(T)
+}
+
 func pickInt(values ...int) int {
 	for _, v := range values {
 		if v > 0 {
@@ -91,5 +173,3 @@ func pickInt(values ...int) int {
 	}
 	return 0
 }
-
-
@@ -14,6 +14,7 @@ type AppDB struct {
 }
 
 // ConnectAppDB connects to the central application Postgres using the provided DSN.
+// It does not change the schema; call Migrate for that.
 func ConnectAppDB(ctx context.Context, dsn string) (*AppDB, error) {
 	if dsn == "" {
 		return nil, errors.New("empty DSN")
@@ -34,12 +35,7 @@ func ConnectAppDB(ctx context.Context, dsn string) (*AppDB, error) {
 		pool.Close()
 		return nil, err
 	}
-	adb := &AppDB{Pool: pool}
-	if err := adb.migrate(ctx); err != nil {
-		pool.Close()
-		return nil, err
-	}
-	return adb, nil
+	return &AppDB{Pool: pool}, nil
 }
 
 // Close closes the pool.
@@ -50,53 +46,24 @@ func (a *AppDB) Close() {
 	a.Pool.Close()
 }
 
-// migrate creates required tables if not present.
-func (a *AppDB) migrate(ctx context.Context) error {
-	// import_jobs: id, customer_id, product_type, blob_uri, status, created_at, updated_at, started_at, finished_at, error_text
-	// import_logs: id, job_id, level, message, created_at, context JSONB
-	_, err := a.Pool.Exec(ctx, `
-CREATE TABLE IF NOT EXISTS import_jobs (
-	id BIGSERIAL PRIMARY KEY,
-	customer_id TEXT NOT NULL,
-	product_type TEXT NOT NULL,
-	blob_uri TEXT NOT NULL,
-	status TEXT NOT NULL DEFAULT 'queued',
-	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-	started_at TIMESTAMPTZ,
-	finished_at TIMESTAMPTZ,
-	error_text TEXT
-);
-
-CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+// appMigrationsTable records which app migrations have been applied.
+const appMigrationsTable = "schema_migrations"
 
-CREATE TABLE IF NOT EXISTS import_logs (
-	id BIGSERIAL PRIMARY KEY,
-	job_id BIGINT REFERENCES import_jobs(id) ON DELETE CASCADE,
-	level TEXT NOT NULL,
-	message TEXT NOT NULL,
-	context JSONB,
-	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-);
-
--- trigger to auto-update updated_at
-DO $$
-BEGIN
-IF NOT EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'update_updated_at') THEN
-  CREATE OR REPLACE FUNCTION update_updated_at()
-  RETURNS TRIGGER AS $trg$
-  BEGIN
-    NEW.updated_at = NOW();
-    RETURN NEW;
-  END;
-  $trg$ LANGUAGE plpgsql;
-END IF;
-END $$;
+// Migrate applies pending app DB migrations in version order and returns them.
+// An advisory lock makes concurrent callers wait, so only one instance migrates.
+func (a *AppDB) Migrate(ctx context.Context) ([]Migration, error) {
+	return a.migrations().apply(ctx, a.Pool, false)
+}
 
-DROP TRIGGER IF EXISTS trg_update_updated_at ON import_jobs;
-CREATE TRIGGER trg_update_updated_at BEFORE UPDATE ON import_jobs
-FOR EACH ROW EXECUTE FUNCTION update_updated_at();
+// PendingMigrations returns the migrations Migrate would apply, without applying them.
+func (a *AppDB) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	return a.migrations().apply(ctx, a.Pool, true)
+}
 
-`)
-	return err
+func (a *AppDB) migrations() migrationSet {
+	return migrationSet{
+		tracking:   appMigrationsTable,
+		lockKey:    appMigrationsTable,
+		migrations: appMigrations,
+	}
 }
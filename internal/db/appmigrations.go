@@ -0,0 +1,142 @@
+package db
+
+// appMigrations is the ordered schema history of the central app database.
+// Append new versions at the end; never edit or renumber one that has shipped.
+// Version 1 uses IF NOT EXISTS so databases created before versioning adopt it cleanly.
+var appMigrations = []Migration{
+	{1, "create_jobs_and_logs", `
+CREATE TABLE IF NOT EXISTS import_jobs (
+	id BIGSERIAL PRIMARY KEY,
+	customer_id TEXT NOT NULL,
+	product_type TEXT NOT NULL,
+	blob_uri TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'queued',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	started_at TIMESTAMPTZ,
+	finished_at TIMESTAMPTZ,
+	error_text TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+
+CREATE TABLE IF NOT EXISTS import_logs (
+	id BIGSERIAL PRIMARY KEY,
+	job_id BIGINT REFERENCES import_jobs(id) ON DELETE CASCADE,
+	level TEXT NOT NULL,
+	message TEXT NOT NULL,
+	context JSONB,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`},
+	{2, "import_jobs_updated_at_trigger", `
+CREATE OR REPLACE FUNCTION update_updated_at()
+RETURNS TRIGGER AS $trg$
+BEGIN
+  NEW.updated_at = NOW();
+  RETURN NEW;
+END;
+$trg$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_update_updated_at ON import_jobs;
+CREATE TRIGGER trg_update_updated_at BEFORE UPDATE ON import_jobs
+FOR EACH ROW EXECUTE FUNCTION update_updated_at();
+`},
+	{3, "job_record_counts_and_log_index", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS records_inserted BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_import_logs_job_id ON import_logs(job_id, id);
+`},
+	{4, "import_jobs_listing_indexes", `
+CREATE INDEX IF NOT EXISTS idx_import_jobs_created ON import_jobs(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_customer_created ON import_jobs(customer_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_product_created ON import_jobs(product_type, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status_created ON import_jobs(status, created_at DESC, id DESC);
+-- superseded by idx_import_jobs_status_created
+DROP INDEX IF EXISTS idx_import_jobs_status;
+`},
+	{5, "import_jobs_retries", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS max_attempts INT NOT NULL DEFAULT 3;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS next_run_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS idx_import_jobs_queued ON import_jobs(next_run_at, created_at) WHERE status = 'queued';
+`},
+	{6, "import_jobs_leases", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS worker_id TEXT;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+-- Jobs already running keep a NULL lease: their pre-upgrade workers never heartbeat, and
+-- a guessed expiry would requeue a long job while it still inserts. The reaper skips
+-- them, so one whose worker died stays running until an operator requeues it.
+CREATE INDEX IF NOT EXISTS idx_import_jobs_lease ON import_jobs(lease_expires_at) WHERE status = 'running';
+`},
+	{7, "import_jobs_cancellation", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS cancel_requested_at TIMESTAMPTZ;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS cancel_rollback BOOLEAN NOT NULL DEFAULT false;
+`},
+	{8, "import_jobs_priority_and_fair_dispatch", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0;
+DROP INDEX IF EXISTS idx_import_jobs_queued;
+CREATE INDEX IF NOT EXISTS idx_import_jobs_queued ON import_jobs(priority DESC, created_at, next_run_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_import_jobs_running_customer ON import_jobs(customer_id) WHERE status = 'running';
+`},
+	{9, "import_jobs_run_at", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS run_at TIMESTAMPTZ;
+`},
+	{10, "import_schedules", `
+CREATE TABLE IF NOT EXISTS import_schedules (
+  id BIGSERIAL PRIMARY KEY,
+  customer_id TEXT NOT NULL,
+  product_type TEXT NOT NULL,
+  blob_uri_template TEXT NOT NULL,
+  cron_expr TEXT NOT NULL,
+  timezone TEXT NOT NULL DEFAULT 'UTC',
+  priority INT NOT NULL DEFAULT 0,
+  enabled BOOLEAN NOT NULL DEFAULT true,
+  next_run_at TIMESTAMPTZ NOT NULL,
+  last_run_at TIMESTAMPTZ,
+  last_job_id BIGINT REFERENCES import_jobs(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_import_schedules_due ON import_schedules(next_run_at) WHERE enabled;
+CREATE INDEX IF NOT EXISTS idx_import_schedules_customer ON import_schedules(customer_id);
+DROP TRIGGER IF EXISTS trg_update_updated_at ON import_schedules;
+CREATE TRIGGER trg_update_updated_at BEFORE UPDATE ON import_schedules
+FOR EACH ROW EXECUTE FUNCTION update_updated_at();
+`},
+	{11, "import_jobs_idempotency_and_content_hash", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS content_sha256 TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_import_jobs_idempotency ON import_jobs(customer_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_import_jobs_content ON import_jobs(customer_id, product_type, content_sha256) WHERE status = 'succeeded';
+`},
+	{12, "import_jobs_checkpoint", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS checkpoint_batch INT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS checkpoint_records BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS checkpoint_at TIMESTAMPTZ;
+`},
+	{13, "import_jobs_progress", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS records_read BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS records_valid BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS records_invalid BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS bytes_read BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS bytes_total BIGINT;
+`},
+	{14, "import_uploads", `
+CREATE TABLE IF NOT EXISTS import_uploads (
+  id BIGSERIAL PRIMARY KEY,
+  customer_id TEXT NOT NULL,
+  product_type TEXT NOT NULL,
+  filename TEXT NOT NULL,
+  content_type TEXT,
+  blob_uri TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  sha256 TEXT NOT NULL,
+  job_id BIGINT REFERENCES import_jobs(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_import_uploads_job ON import_uploads(job_id);
+`},
+	{15, "import_jobs_records_resumed", `
+ALTER TABLE import_jobs ADD COLUMN IF NOT EXISTS records_resumed BIGINT NOT NULL DEFAULT 0;
+`},
+}
@@ -11,10 +11,13 @@ import (
 // CustomerDB manages connections to a specific customer's Postgres database.
 type CustomerDB struct {
 	Pool *pgxpool.Pool
+	// Schema holding the customer's tables; empty means the connection's search_path.
+	Schema string
 }
 
 // ConnectCustomerDB connects to the customer's Postgres using the given DSN.
-func ConnectCustomerDB(ctx context.Context, dsn string) (*CustomerDB, error) {
+// A non-empty schema scopes all target tables to that schema.
+func ConnectCustomerDB(ctx context.Context, dsn, schema string) (*CustomerDB, error) {
 	if dsn == "" {
 		return nil, errors.New("empty customer DSN")
 	}
@@ -26,33 +29,52 @@ func ConnectCustomerDB(ctx context.Context, dsn string) (*CustomerDB, error) {
 		pool.Close()
 		return nil, err
 	}
-	return &CustomerDB{Pool: pool}, nil
+	return &CustomerDB{Pool: pool, Schema: schema}, nil
 }
 
-// EnsureTargetTable ensures a table exists with the given name and a JSONB column named data.
-func (c *CustomerDB) EnsureTargetTable(ctx context.Context, tableName string) error {
-	if tableName == "" {
-		return errors.New("empty table name")
+// qualify returns the quoted, schema-qualified identifier for a table.
+func (c *CustomerDB) qualify(tableName string) string {
+	return TargetTable{Schema: c.Schema, Table: tableName}.Ident()
+}
+
+// InsertJSONB inserts a row into the target table with the JSONB document, tagged with
+// the import job that produced it.
+func (c *CustomerDB) InsertJSONB(ctx context.Context, tableName string, jobID int64, data []byte) error {
+	//fmt.Println("Inserting into table:%s, values: %s", tableName, string(data))
+	ddl := fmt.Sprintf("INSERT INTO %s (data, import_job_id) VALUES ($1, $2)", c.qualify(tableName))
+	_, err := c.Pool.Exec(ctx, ddl, data, jobID)
+	return err
+}
+
+// InsertBatch inserts JSONB documents tagged with the import job in one transaction,
+// so a batch is either fully committed or not at all.
+func (c *CustomerDB) InsertBatch(ctx context.Context, tableName string, jobID int64, docs [][]byte) error {
+	tx, err := c.Pool.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	// Simple validation to avoid SQL injection from tableName
-	for _, r := range tableName {
-		if !(r == '_' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z')) {
-			return fmt.Errorf("invalid table name: %s", tableName)
+	defer func() { _ = tx.Rollback(ctx) }()
+	ddl := fmt.Sprintf("INSERT INTO %s (data, import_job_id) VALUES ($1, $2)", c.qualify(tableName))
+	for _, d := range docs {
+		if _, err := tx.Exec(ctx, ddl, d, jobID); err != nil {
+			return err
 		}
 	}
-	ddl := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-		id BIGSERIAL PRIMARY KEY,
-		data JSONB NOT NULL,
-		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	);`, tableName)
-	_, err := c.Pool.Exec(ctx, ddl)
-	return err
+	return tx.Commit(ctx)
 }
 
-// InsertJSONB inserts a row into the target table with the JSONB document.
-func (c *CustomerDB) InsertJSONB(ctx context.Context, tableName string, data []byte) error {
-	//fmt.Println("Inserting into table:%s, values: %s", tableName, string(data))
-	ddl := fmt.Sprintf("INSERT INTO %s (data) VALUES ($1)", tableName)
-	_, err := c.Pool.Exec(ctx, ddl, data)
-	return err
+// CountJobRows returns how many rows an import job has committed to the target table.
+func (c *CustomerDB) CountJobRows(ctx context.Context, tableName string, jobID int64) (int64, error) {
+	var n int64
+	err := c.Pool.QueryRow(ctx, fmt.Sprintf("SELECT count(*) FROM %s WHERE import_job_id = $1", c.qualify(tableName)), jobID).Scan(&n)
+	return n, err
+}
+
+// DeleteJobRows removes the rows an import job inserted into the target table.
+func (c *CustomerDB) DeleteJobRows(ctx context.Context, tableName string, jobID int64) (int64, error) {
+	tag, err := c.Pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE import_job_id = $1", c.qualify(tableName)), jobID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/user/importer/internal/products"
+)
+
+// customerMigrationsTable tracks applied product migrations in each customer database (or schema).
+const customerMigrationsTable = "import_schema_migrations"
+
+// TargetTable names a product table in a customer database when building migration SQL.
+type TargetTable struct {
+	Schema string
+	Table  string
+}
+
+// Ident returns the quoted, schema-qualified table name.
+func (t TargetTable) Ident() string {
+	if t.Schema == "" {
+		return pgx.Identifier{t.Table}.Sanitize()
+	}
+	return pgx.Identifier{t.Schema, t.Table}.Sanitize()
+}
+
+// Index returns a quoted index name derived from the table name and suffix.
+// Indexes live in the table's schema, so the name is never qualified.
+func (t TargetTable) Index(suffix string) string {
+	return pgx.Identifier{t.Table + "_" + suffix}.Sanitize()
+}
+
+// IndexIdent returns the quoted, schema-qualified name of the index Index names.
+func (t TargetTable) IndexIdent(suffix string) string {
+	return TargetTable{Schema: t.Schema, Table: t.Table + "_" + suffix}.Ident()
+}
+
+// ProductMigration is a migration declared for a product's target table.
+// SQL is rendered against each customer's table at apply time.
+type ProductMigration struct {
+	Version int
+	Name    string
+	SQL     func(t TargetTable) string
+	// Index is set on migrations built by indexMigration: the suffix of the index
+	// they create concurrently.
+	Index string
+}
+
+// Migration SQL shared by several products.
+
+func createTable(t TargetTable) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`, t.Ident())
+}
+
+// indexMigration declares a migration building the index with the given suffix on
+// the table's expr. It is built with CREATE INDEX CONCURRENTLY, so writes to a large
+// existing table go on meanwhile, and jobs leave it to Migrate (see MigrateForImport).
+func indexMigration(version int, name, suffix, expr string) ProductMigration {
+	return ProductMigration{Version: version, Name: name, Index: suffix, SQL: func(t TargetTable) string {
+		return fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)`, t.Index(suffix), t.Ident(), expr)
+	}}
+}
+
+// addImportJobID tags rows with the import job that wrote them, so a job's rows can be
+// removed. Adding a nullable column without a default does not rewrite the table.
+func addImportJobID(t TargetTable) string {
+	return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS import_job_id BIGINT`, t.Ident())
+}
+
+// productMigrations declares the ordered migrations for each product's target table.
+// Append new versions at the end; never edit or renumber one that has shipped.
+var productMigrations = map[string][]ProductMigration{
+	products.ProductUsers: {
+		{Version: 1, Name: "create_table", SQL: createTable},
+		indexMigration(2, "index_data_id", "data_id_idx", "(data->>'id')"),
+		indexMigration(3, "index_data_email", "data_email_idx", "lower(data->>'email')"),
+		{Version: 4, Name: "import_job_id", SQL: addImportJobID},
+		indexMigration(5, "index_import_job_id", "import_job_id_idx", "import_job_id"),
+	},
+	products.ProductOrganizations: {
+		{Version: 1, Name: "create_table", SQL: createTable},
+		indexMigration(2, "index_data_id", "data_id_idx", "(data->>'id')"),
+		{Version: 3, Name: "import_job_id", SQL: addImportJobID},
+		indexMigration(4, "index_import_job_id", "import_job_id_idx", "import_job_id"),
+	},
+	products.ProductCourses: {
+		{Version: 1, Name: "create_table", SQL: createTable},
+		indexMigration(2, "index_data_id", "data_id_idx", "(data->>'id')"),
+		{Version: 3, Name: "import_job_id", SQL: addImportJobID},
+		indexMigration(4, "index_import_job_id", "import_job_id_idx", "import_job_id"),
+	},
+}
+
+// Migrate creates or upgrades a product's target table by applying its pending
+// migrations, and returns the ones applied.
+func (c *CustomerDB) Migrate(ctx context.Context, productType string) ([]Migration, error) {
+	return c.migrate(ctx, productType, false, false)
+}
+
+// MigrateForImport is Migrate for a job about to import into the table. Index
+// migrations on a table that already exists are left pending for Migrate (cli
+// migrate-customers), so a job never waits for an index build on a large table; on a
+// new table they are cheap and applied right away.
+func (c *CustomerDB) MigrateForImport(ctx context.Context, productType string) ([]Migration, error) {
+	return c.migrate(ctx, productType, false, true)
+}
+
+// PendingMigrations returns the migrations Migrate would apply for a product.
+func (c *CustomerDB) PendingMigrations(ctx context.Context, productType string) ([]Migration, error) {
+	return c.migrate(ctx, productType, true, false)
+}
+
+func (c *CustomerDB) migrate(ctx context.Context, productType string, dryRun, deferIndexes bool) ([]Migration, error) {
+	table, err := products.TargetTableFor(productType)
+	if err != nil {
+		return nil, err
+	}
+	decl, ok := productMigrations[productType]
+	if !ok {
+		return nil, errors.New("no migrations declared for product type " + productType)
+	}
+	t := TargetTable{Schema: c.Schema, Table: table}
+	set := migrationSet{
+		tracking: TargetTable{Schema: c.Schema, Table: customerMigrationsTable}.Ident(),
+		scope:    productType,
+		lockKey:  customerMigrationsTable + ":" + c.Schema,
+		indexes:  map[int]string{},
+	}
+	for _, d := range decl {
+		set.migrations = append(set.migrations, Migration{Version: d.Version, Name: d.Name, SQL: d.SQL(t)})
+		if d.Index != "" {
+			set.indexes[d.Version] = t.IndexIdent(d.Index)
+		}
+	}
+	if deferIndexes {
+		var exists bool
+		if err := c.Pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, t.Ident()).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			set.skip = func(m Migration) bool {
+				_, ok := set.indexes[m.Version]
+				return ok
+			}
+		}
+	}
+	if c.Schema != "" && !dryRun {
+		if _, err := c.Pool.Exec(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{c.Schema}.Sanitize()); err != nil {
+			return nil, fmt.Errorf("create schema %s: %w", c.Schema, err)
+		}
+	}
+	return set.apply(ctx, c.Pool, dryRun)
+}
@@ -0,0 +1,97 @@
+package db_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/db/dbtest"
+)
+
+func customerDB(t *testing.T) *db.CustomerDB {
+	t.Helper()
+	dsn, schema := dbtest.CustomerTarget(t)
+	cdb, err := db.ConnectCustomerDB(context.Background(), dsn, schema)
+	if err != nil {
+		t.Fatalf("ConnectCustomerDB: %v", err)
+	}
+	t.Cleanup(cdb.Pool.Close)
+	return cdb
+}
+
+func names(migs []db.Migration) []string {
+	out := []string{}
+	for _, m := range migs {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
+// validIndexes counts the valid indexes of the users table besides its primary key.
+func validIndexes(t *testing.T, cdb *db.CustomerDB) int {
+	t.Helper()
+	var n int
+	if err := cdb.Pool.QueryRow(context.Background(), `
+SELECT count(*) FROM pg_index WHERE indrelid = to_regclass($1) AND indisvalid AND NOT indisprimary`,
+		db.TargetTable{Schema: cdb.Schema, Table: "users"}.Ident()).Scan(&n); err != nil {
+		t.Fatalf("count indexes: %v", err)
+	}
+	return n
+}
+
+func TestMigrateForImportNewTable(t *testing.T) {
+	ctx := context.Background()
+	cdb := customerDB(t)
+	applied, err := cdb.MigrateForImport(ctx, "users")
+	if err != nil {
+		t.Fatalf("MigrateForImport: %v", err)
+	}
+	want := []string{"create_table", "index_data_id", "index_data_email", "import_job_id", "index_import_job_id"}
+	if got := names(applied); !reflect.DeepEqual(got, want) {
+		t.Fatalf("applied = %v, want %v", got, want)
+	}
+	if n := validIndexes(t, cdb); n != 3 {
+		t.Fatalf("valid indexes = %d, want 3", n)
+	}
+}
+
+func TestMigrateForImportDefersIndexesOnExistingTable(t *testing.T) {
+	ctx := context.Background()
+	cdb := customerDB(t)
+	// a table created before migrations were tracked
+	schema := pgx.Identifier{cdb.Schema}.Sanitize()
+	if _, err := cdb.Pool.Exec(ctx, `CREATE SCHEMA `+schema+`;
+CREATE TABLE `+schema+`.users (id BIGSERIAL PRIMARY KEY, data JSONB NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now())`); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+
+	applied, err := cdb.MigrateForImport(ctx, "users")
+	if err != nil {
+		t.Fatalf("MigrateForImport: %v", err)
+	}
+	if got, want := names(applied), []string{"create_table", "import_job_id"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("applied by the job = %v, want %v", got, want)
+	}
+	if n := validIndexes(t, cdb); n != 0 {
+		t.Fatalf("valid indexes after the job = %d, want 0", n)
+	}
+	if again, err := cdb.MigrateForImport(ctx, "users"); err != nil || len(again) != 0 {
+		t.Fatalf("second MigrateForImport = %v, %v; want nothing", names(again), err)
+	}
+
+	deferred := []string{"index_data_id", "index_data_email", "index_import_job_id"}
+	pending, err := cdb.PendingMigrations(ctx, "users")
+	if err != nil || !reflect.DeepEqual(names(pending), deferred) {
+		t.Fatalf("pending = %v, %v; want %v", names(pending), err, deferred)
+	}
+	applied, err = cdb.Migrate(ctx, "users")
+	if err != nil || !reflect.DeepEqual(names(applied), deferred) {
+		t.Fatalf("Migrate = %v, %v; want %v", names(applied), err, deferred)
+	}
+	if n := validIndexes(t, cdb); n != 3 {
+		t.Fatalf("valid indexes = %d, want 3", n)
+	}
+}
@@ -0,0 +1,84 @@
+// Package dbtest provides app databases for tests that need Postgres.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/importer/internal/db"
+)
+
+// DSNEnv names the environment variable holding the DSN of a Postgres database the
+// tests may create schemas in. Tests needing Postgres are skipped when it is unset.
+const DSNEnv = "TEST_APP_DB_DSN"
+
+// AppDB returns an app database in a fresh schema with all migrations applied. The
+// schema is dropped when the test ends.
+func AppDB(t *testing.T) *db.AppDB {
+	t.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skip(DSNEnv + " is not set")
+	}
+	ctx := context.Background()
+	admin, err := db.ConnectAppDB(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect %s: %v", DSNEnv, err)
+	}
+	schema := fmt.Sprintf("importer_test_%d", time.Now().UnixNano())
+	if _, err := admin.Pool.Exec(ctx, `CREATE SCHEMA `+schema); err != nil {
+		admin.Close()
+		t.Fatalf("create schema: %v", err)
+	}
+	adb, err := db.ConnectAppDB(ctx, withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		adb.Close()
+		_, _ = admin.Pool.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`)
+		admin.Close()
+	})
+	if _, err := adb.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return adb
+}
+
+// CustomerTarget returns the DSN and a fresh schema for a customer's target tables.
+// The schema is created by the importer's table migrations and dropped when the test
+// ends.
+func CustomerTarget(t *testing.T) (dsn, schema string) {
+	t.Helper()
+	dsn = os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skip(DSNEnv + " is not set")
+	}
+	schema = fmt.Sprintf("importer_test_customer_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if admin, err := db.ConnectAppDB(ctx, dsn); err == nil {
+			_, _ = admin.Pool.Exec(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE`)
+			admin.Close()
+		}
+	})
+	return dsn, schema
+}
+
+// withSearchPath sets search_path on a URL or key=value DSN.
+func withSearchPath(dsn, schema string) string {
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration is one versioned schema change, applied in its own transaction unless it
+// builds an index concurrently (see migrationSet.indexes).
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// migrationSet is an ordered list of migrations tracked under a scope in a tracking table.
+// Several sets (e.g. one per product) may share a tracking table with different scopes.
+type migrationSet struct {
+	// Quoted, optionally schema-qualified tracking table name.
+	tracking string
+	// Scope distinguishes independent sets recorded in the same tracking table.
+	scope string
+	// Advisory lock key so only one process migrates a database at a time.
+	lockKey    string
+	migrations []Migration
+	// indexes names, by version, the qualified index each CREATE INDEX CONCURRENTLY
+	// migration builds. Such a migration cannot run in a transaction, so it runs on its
+	// own and is recorded once the index is built; an invalid index left by a failed
+	// attempt is dropped before the next one.
+	indexes map[int]string
+	// skip, when set, leaves the pending migrations it matches for a later run.
+	skip func(Migration) bool
+}
+
+// apply runs pending migrations in version order and returns them.
+// With dryRun it only reports what is pending and changes nothing.
+func (m migrationSet) apply(ctx context.Context, pool *pgxpool.Pool, dryRun bool) ([]Migration, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	// Checked before locking too, so callers with nothing to apply never wait for a
+	// migration holding the lock, such as a long index build.
+	pending, err := m.pending(ctx, conn.Conn())
+	if err != nil || dryRun || len(pending) == 0 {
+		return pending, err
+	}
+
+	// Session-level lock, held across the per-migration transactions below.
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext($1))`, m.lockKey); err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, m.lockKey)
+
+	if _, err := conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	scope TEXT NOT NULL DEFAULT '',
+	version INT NOT NULL,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (scope, version)
+)`, m.tracking)); err != nil {
+		return nil, fmt.Errorf("create migration tracking table: %w", err)
+	}
+	// another process may have applied some while this one waited for the lock
+	if pending, err = m.pending(ctx, conn.Conn()); err != nil {
+		return nil, err
+	}
+
+	for i, mig := range pending {
+		if index, ok := m.indexes[mig.Version]; ok {
+			err = m.applyConcurrently(ctx, conn.Conn(), mig, index)
+		} else {
+			err = m.applyInTx(ctx, conn.Conn(), mig)
+		}
+		if err != nil {
+			return pending[:i], err
+		}
+	}
+	return pending, nil
+}
+
+// pending returns the migrations not recorded in the tracking table, minus skipped ones.
+func (m migrationSet) pending(ctx context.Context, conn *pgx.Conn) ([]Migration, error) {
+	applied := map[int]bool{}
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, m.tracking).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT version FROM %s WHERE scope = $1`, m.tracking), m.scope)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var v int
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			applied[v] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] && (m.skip == nil || !m.skip(mig)) {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+func (m migrationSet) applyInTx(ctx context.Context, conn *pgx.Conn, mig Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, mig.SQL); err != nil {
+		_ = tx.Rollback(ctx)
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if err := m.record(ctx, tx, mig); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// applyConcurrently builds a migration's index without blocking writes to its table.
+func (m migrationSet) applyConcurrently(ctx context.Context, conn *pgx.Conn, mig Migration, index string) error {
+	var invalid bool
+	if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_index WHERE indexrelid = to_regclass($1) AND NOT indisvalid)`, index).Scan(&invalid); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if invalid {
+		if _, err := conn.Exec(ctx, `DROP INDEX CONCURRENTLY IF EXISTS `+index); err != nil {
+			return fmt.Errorf("migration %d_%s: drop invalid index: %w", mig.Version, mig.Name, err)
+		}
+	}
+	if _, err := conn.Exec(ctx, mig.SQL); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return m.record(ctx, conn, mig)
+}
+
+// execer runs statements on a connection or in a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (m migrationSet) record(ctx context.Context, q execer, mig Migration) error {
+	if _, err := q.Exec(ctx, fmt.Sprintf(`INSERT INTO %s (scope, version, name) VALUES ($1, $2, $3)`, m.tracking), m.scope, mig.Version, mig.Name); err != nil {
+		return fmt.Errorf("record migration %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
@@ -10,38 +10,96 @@ import (
 // ImporterServer defines the gRPC server interface.
 type ImporterServer interface {
 	Enqueue(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetJob(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	GetJobLogs(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ListJobs(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	ReplayJob(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	CancelJob(context.Context, *structpb.Struct) (*structpb.Struct, error)
+	WatchJob(*structpb.Struct, ImporterWatchJobServer) error
 }
 
-// ImporterServiceDesc describes the Importer service for manual registration.
+// ImporterWatchJobServer is the server side of a WatchJob stream.
+type ImporterWatchJobServer interface {
+	Send(*structpb.Struct) error
+	grpc.ServerStream
+}
+
+type importerWatchJobServer struct {
+	grpc.ServerStream
+}
+
+func (x *importerWatchJobServer) Send(m *structpb.Struct) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ImporterServiceDesc describes the Struct-based importer.Importer service for manual registration.
+//
+// Deprecated: the typed importer.v1.Importer service is generated from
+// api/importer/v1/importer.proto; register it with importerv1.RegisterImporterServer.
 var ImporterServiceDesc = grpc.ServiceDesc{
 	ServiceName: "importer.Importer",
 	HandlerType: (*ImporterServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
 			MethodName: "Enqueue",
-			Handler:    _Importer_Enqueue_Handler,
+			Handler:    unaryHandler("Enqueue", ImporterServer.Enqueue),
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    unaryHandler("GetJob", ImporterServer.GetJob),
+		},
+		{
+			MethodName: "GetJobLogs",
+			Handler:    unaryHandler("GetJobLogs", ImporterServer.GetJobLogs),
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    unaryHandler("ListJobs", ImporterServer.ListJobs),
+		},
+		{
+			MethodName: "ReplayJob",
+			Handler:    unaryHandler("ReplayJob", ImporterServer.ReplayJob),
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    unaryHandler("CancelJob", ImporterServer.CancelJob),
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchJob",
+			Handler:       watchJobHandler,
+			ServerStreams: true,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "importer",
 }
 
-func _Importer_Enqueue_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(structpb.Struct)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(ImporterServer).Enqueue(ctx, in)
+// unaryHandler adapts a Struct-in, Struct-out ImporterServer method to a grpc.MethodDesc handler.
+func unaryHandler(method string, call func(ImporterServer, context.Context, *structpb.Struct) (*structpb.Struct, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(ImporterServer), ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/importer.Importer/" + method,
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(ImporterServer), ctx, req.(*structpb.Struct))
+		}
+		return interceptor(ctx, in, info, handler)
 	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/importer.Importer/Enqueue",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ImporterServer).Enqueue(ctx, req.(*structpb.Struct))
-	}
-	return interceptor(ctx, in, info, handler)
 }
 
-
+func watchJobHandler(srv interface{}, stream grpc.ServerStream) error {
+	in := new(structpb.Struct)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(ImporterServer).WatchJob(in, &importerWatchJobServer{stream})
+}
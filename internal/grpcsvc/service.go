@@ -2,21 +2,38 @@ package grpcsvc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/importer"
 	"github.com/user/importer/internal/jobs"
 )
 
-// ImporterService provides gRPC methods for enqueuing import jobs.
+// ImporterService provides the Struct-based importer.Importer methods.
+//
+// Deprecated: clients should move to the typed importer.v1.Importer service (see V1);
+// this one stays registered for a deprecation period.
 type ImporterService struct {
 	Jobs *jobs.Repository
+	// Importer applies the enqueue policies, such as the blob URI checks.
+	Importer *importer.Service
+	// Hub delivers job changes to WatchJob streams.
+	Hub *jobs.Hub
 }
 
-func New(jr *jobs.Repository) *ImporterService { return &ImporterService{Jobs: jr} }
+func New(jr *jobs.Repository, imp *importer.Service, hub *jobs.Hub) *ImporterService {
+	return &ImporterService{Jobs: jr, Importer: imp, Hub: hub}
+}
 
-// Enqueue expects a Struct with fields: customer_id, product_type, blob_uri. Returns { job_id: number }.
+// Enqueue expects a Struct with fields: customer_id, product_type, blob_uri and optional
+// priority, run_at (RFC 3339) and idempotency_key. A repeat with the same idempotency_key
+// returns the original job. Returns { job_id: number }.
 func (s *ImporterService) Enqueue(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
 	if in == nil {
 		return nil, errors.New("nil request")
@@ -33,12 +50,196 @@ func (s *ImporterService) Enqueue(ctx context.Context, in *structpb.Struct) (*st
 	if cust == "" || prod == "" || blob == "" {
 		return nil, errors.New("customer_id, product_type, and blob_uri are required")
 	}
-	id, err := s.Jobs.Enqueue(ctx, cust, prod, blob)
+	opts := jobs.EnqueueOptions{
+		Priority:       int(in.Fields["priority"].GetNumberValue()),
+		IdempotencyKey: get("idempotency_key"),
+	}
+	if v := get("run_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid run_at: expected RFC 3339 time")
+		}
+		opts.RunAt = t
+	}
+	id, err := s.Importer.Enqueue(ctx, cust, prod, blob, opts)
 	if err != nil {
-		return nil, err
+		return nil, enqueueError(err)
 	}
 	resp, _ := structpb.NewStruct(map[string]any{"job_id": id})
 	return resp, nil
 }
 
+// GetJob expects { job_id: number } and returns the job's status, timestamps, error text and record counts.
+func (s *ImporterService) GetJob(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	id, err := jobIDField(in)
+	if err != nil {
+		return nil, err
+	}
+	j, err := s.Jobs.Get(ctx, id)
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return toStruct(j)
+}
 
+// GetJobLogs expects { job_id: number, after?: number, limit?: number } and returns
+// { logs: [...], next_after: number }. Pass next_after as after to fetch the next page.
+func (s *ImporterService) GetJobLogs(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	id, err := jobIDField(in)
+	if err != nil {
+		return nil, err
+	}
+	after := int64(in.Fields["after"].GetNumberValue())
+	limit := int(in.Fields["limit"].GetNumberValue())
+	if _, err := s.Jobs.Get(ctx, id); err != nil {
+		return nil, jobError(err)
+	}
+	logs, err := s.Jobs.Logs(ctx, id, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	next := after
+	if len(logs) > 0 {
+		next = logs[len(logs)-1].ID
+	}
+	return toStruct(map[string]any{"logs": logs, "next_after": next})
+}
+
+// ListJobs expects optional filters { customer_id, product_type, status, created_from,
+// created_to (RFC 3339), cursor, limit } and returns { jobs: [...], next_cursor: string },
+// newest first. Pass next_cursor as cursor to fetch the next page.
+func (s *ImporterService) ListJobs(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	if in == nil {
+		return nil, errors.New("nil request")
+	}
+	get := func(k string) string { return in.Fields[k].GetStringValue() }
+	f := jobs.ListFilter{
+		CustomerID:  get("customer_id"),
+		ProductType: get("product_type"),
+		Status:      jobs.Status(get("status")),
+		Cursor:      get("cursor"),
+		Limit:       int(in.Fields["limit"].GetNumberValue()),
+	}
+	for k, dst := range map[string]*time.Time{"created_from": &f.CreatedFrom, "created_to": &f.CreatedTo} {
+		if v := get(k); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s: expected RFC 3339 time", k)
+			}
+			*dst = t
+		}
+	}
+	list, next, err := s.Jobs.List(ctx, f)
+	if err != nil {
+		if errors.Is(err, jobs.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+	return toStruct(map[string]any{"jobs": list, "next_cursor": next})
+}
+
+// ReplayJob expects { job_id: number } and requeues a dead or failed job with fresh attempts.
+func (s *ImporterService) ReplayJob(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	id, err := jobIDField(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Jobs.Replay(ctx, id); err != nil {
+		return nil, jobError(err)
+	}
+	return toStruct(map[string]any{"job_id": id, "status": jobs.StatusQueued})
+}
+
+// CancelJob expects { job_id: number, rollback?: bool }. A queued job is cancelled at once;
+// a running job is stopped by its worker, which deletes the rows it inserted when rollback is set.
+// Returns { job_id, status, cancel_requested }.
+func (s *ImporterService) CancelJob(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	id, err := jobIDField(in)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.Jobs.Cancel(ctx, id, in.Fields["rollback"].GetBoolValue())
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return toStruct(map[string]any{"job_id": id, "status": st, "cancel_requested": true})
+}
+
+// WatchJob expects { job_id: number, after?: number } and streams { type, job } messages
+// for the job's status ("status", first and on each transition) and progress
+// ("progress"), and { type: "log", log } for log entries with id greater than after.
+// The stream ends OK once the job reaches a terminal status, which the last status
+// message carries.
+func (s *ImporterService) WatchJob(in *structpb.Struct, stream ImporterWatchJobServer) error {
+	id, err := jobIDField(in)
+	if err != nil {
+		return err
+	}
+	after := int64(in.Fields["after"].GetNumberValue())
+	err = s.Hub.Watch(stream.Context(), id, after, func(ev jobs.Event) error {
+		msg := map[string]any{"type": ev.Type}
+		if ev.Type == jobs.EventLog {
+			msg["log"] = ev.Log
+		} else {
+			msg["job"] = ev.Job
+		}
+		out, err := toStruct(msg)
+		if err != nil {
+			return err
+		}
+		return stream.Send(out)
+	})
+	if cerr := stream.Context().Err(); cerr != nil {
+		return status.FromContextError(cerr).Err()
+	}
+	return jobError(err)
+}
+
+func jobIDField(in *structpb.Struct) (int64, error) {
+	if in == nil {
+		return 0, errors.New("nil request")
+	}
+	id := int64(in.Fields["job_id"].GetNumberValue())
+	if id <= 0 {
+		return 0, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	return id, nil
+}
+
+func enqueueError(err error) error {
+	if errors.Is(err, jobs.ErrDuplicateContent) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, jobs.ErrIdempotencyConflict) || errors.Is(err, blob.ErrUnsupportedScheme) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, blob.ErrOutsideRoots) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return err
+}
+
+func jobError(err error) error {
+	if errors.Is(err, jobs.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, jobs.ErrNotReplayable) || errors.Is(err, jobs.ErrNotCancellable) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return err
+}
+
+// toStruct converts a value to a Struct through its JSON form, so gRPC responses
+// carry the same field names as the REST API.
+func toStruct(v any) (*structpb.Struct, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(m)
+}
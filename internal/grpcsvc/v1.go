@@ -0,0 +1,285 @@
+package grpcsvc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	importerv1 "github.com/user/importer/api/importer/v1"
+	"github.com/user/importer/internal/importer"
+	"github.com/user/importer/internal/jobs"
+)
+
+// V1 implements the typed importer.v1.Importer service.
+type V1 struct {
+	importerv1.UnimplementedImporterServer
+	svc *ImporterService
+}
+
+// NewV1 serves the typed API from the same repository, importer and hub as svc.
+func NewV1(svc *ImporterService) *V1 { return &V1{svc: svc} }
+
+func (s *V1) Enqueue(ctx context.Context, in *importerv1.EnqueueRequest) (*importerv1.EnqueueResponse, error) {
+	if in.GetCustomerId() == "" || in.GetProductType() == "" || in.GetBlobUri() == "" {
+		return nil, status.Error(codes.InvalidArgument, "customer_id, product_type, and blob_uri are required")
+	}
+	opts := jobs.EnqueueOptions{Priority: int(in.GetPriority()), IdempotencyKey: in.GetIdempotencyKey()}
+	if in.RunAt != nil {
+		opts.RunAt = in.RunAt.AsTime()
+	}
+	id, err := s.svc.Importer.Enqueue(ctx, in.GetCustomerId(), in.GetProductType(), in.GetBlobUri(), opts)
+	if err != nil {
+		return nil, enqueueError(err)
+	}
+	return &importerv1.EnqueueResponse{JobId: id}, nil
+}
+
+func (s *V1) GetJob(ctx context.Context, in *importerv1.GetJobRequest) (*importerv1.Job, error) {
+	if in.GetJobId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	j, err := s.svc.Jobs.Get(ctx, in.GetJobId())
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return jobToPB(j), nil
+}
+
+func (s *V1) GetJobLogs(ctx context.Context, in *importerv1.GetJobLogsRequest) (*importerv1.GetJobLogsResponse, error) {
+	if in.GetJobId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	if _, err := s.svc.Jobs.Get(ctx, in.GetJobId()); err != nil {
+		return nil, jobError(err)
+	}
+	logs, err := s.svc.Jobs.Logs(ctx, in.GetJobId(), in.GetAfter(), int(in.GetLimit()))
+	if err != nil {
+		return nil, err
+	}
+	out := &importerv1.GetJobLogsResponse{NextAfter: in.GetAfter()}
+	for i := range logs {
+		out.Logs = append(out.Logs, logToPB(&logs[i]))
+		out.NextAfter = logs[i].ID
+	}
+	return out, nil
+}
+
+func (s *V1) ListJobs(ctx context.Context, in *importerv1.ListJobsRequest) (*importerv1.ListJobsResponse, error) {
+	f := jobs.ListFilter{
+		CustomerID:  in.GetCustomerId(),
+		ProductType: in.GetProductType(),
+		Status:      statusFromPB(in.GetStatus()),
+		Cursor:      in.GetCursor(),
+		Limit:       int(in.GetLimit()),
+	}
+	if in.CreatedFrom != nil {
+		f.CreatedFrom = in.CreatedFrom.AsTime()
+	}
+	if in.CreatedTo != nil {
+		f.CreatedTo = in.CreatedTo.AsTime()
+	}
+	list, next, err := s.svc.Jobs.List(ctx, f)
+	if err != nil {
+		if errors.Is(err, jobs.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, err
+	}
+	out := &importerv1.ListJobsResponse{NextCursor: next}
+	for i := range list {
+		out.Jobs = append(out.Jobs, jobToPB(&list[i]))
+	}
+	return out, nil
+}
+
+func (s *V1) CancelJob(ctx context.Context, in *importerv1.CancelJobRequest) (*importerv1.CancelJobResponse, error) {
+	if in.GetJobId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	st, err := s.svc.Jobs.Cancel(ctx, in.GetJobId(), in.GetRollback())
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return &importerv1.CancelJobResponse{JobId: in.GetJobId(), Status: statusToPB(st), CancelRequested: true}, nil
+}
+
+func (s *V1) ReplayJob(ctx context.Context, in *importerv1.ReplayJobRequest) (*importerv1.ReplayJobResponse, error) {
+	if in.GetJobId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	if err := s.svc.Jobs.Replay(ctx, in.GetJobId()); err != nil {
+		return nil, jobError(err)
+	}
+	return &importerv1.ReplayJobResponse{JobId: in.GetJobId(), Status: importerv1.JobStatus_JOB_STATUS_QUEUED}, nil
+}
+
+func (s *V1) WatchJob(in *importerv1.WatchJobRequest, stream grpc.ServerStreamingServer[importerv1.JobEvent]) error {
+	if in.GetJobId() <= 0 {
+		return status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	err := s.svc.Hub.Watch(stream.Context(), in.GetJobId(), in.GetAfter(), func(ev jobs.Event) error {
+		out := &importerv1.JobEvent{}
+		switch ev.Type {
+		case jobs.EventStatus:
+			out.Event = &importerv1.JobEvent_Status{Status: jobToPB(ev.Job)}
+		case jobs.EventProgress:
+			out.Event = &importerv1.JobEvent_Progress{Progress: jobToPB(ev.Job)}
+		case jobs.EventLog:
+			out.Event = &importerv1.JobEvent_Log{Log: logToPB(ev.Log)}
+		}
+		return stream.Send(out)
+	})
+	if cerr := stream.Context().Err(); cerr != nil {
+		return status.FromContextError(cerr).Err()
+	}
+	return jobError(err)
+}
+
+func (s *V1) Upload(stream grpc.ClientStreamingServer[importerv1.UploadRequest, importerv1.UploadResponse]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	md := first.GetMetadata()
+	if md == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry metadata")
+	}
+	res, err := s.svc.Importer.Upload(stream.Context(), importer.Upload{
+		CustomerID:  md.GetCustomerId(),
+		ProductType: md.GetProductType(),
+		Filename:    md.GetFilename(),
+		SHA256:      md.GetSha256(),
+		Priority:    int(md.GetPriority()),
+	}, &chunkReader{stream: stream})
+	if err != nil {
+		if errors.Is(err, importer.ErrInvalidUpload) || errors.Is(err, importer.ErrChecksumMismatch) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, importer.ErrUploadTooLarge) {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return enqueueError(err)
+	}
+	return stream.SendAndClose(&importerv1.UploadResponse{
+		JobId:     *res.JobID,
+		BlobUri:   res.BlobURI,
+		Sha256:    res.SHA256,
+		SizeBytes: res.SizeBytes,
+		UploadId:  res.ID,
+	})
+}
+
+// chunkReader reads the file chunks that follow an Upload stream's metadata message.
+type chunkReader struct {
+	stream grpc.ClientStreamingServer[importerv1.UploadRequest, importerv1.UploadResponse]
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		chunk, ok := msg.Payload.(*importerv1.UploadRequest_Chunk)
+		if !ok {
+			return 0, status.Error(codes.InvalidArgument, "metadata must be sent only in the first message")
+		}
+		r.buf = chunk.Chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+var statusValues = map[jobs.Status]importerv1.JobStatus{
+	jobs.StatusQueued:    importerv1.JobStatus_JOB_STATUS_QUEUED,
+	jobs.StatusScheduled: importerv1.JobStatus_JOB_STATUS_SCHEDULED,
+	jobs.StatusRunning:   importerv1.JobStatus_JOB_STATUS_RUNNING,
+	jobs.StatusSucceeded: importerv1.JobStatus_JOB_STATUS_SUCCEEDED,
+	jobs.StatusFailed:    importerv1.JobStatus_JOB_STATUS_FAILED,
+	jobs.StatusDead:      importerv1.JobStatus_JOB_STATUS_DEAD,
+	jobs.StatusCancelled: importerv1.JobStatus_JOB_STATUS_CANCELLED,
+}
+
+func statusToPB(st jobs.Status) importerv1.JobStatus { return statusValues[st] }
+
+// statusFromPB maps a status filter; UNSPECIFIED means no filter.
+func statusFromPB(st importerv1.JobStatus) jobs.Status {
+	for k, v := range statusValues {
+		if v == st {
+			return k
+		}
+	}
+	return ""
+}
+
+func timePB(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func jobToPB(j *jobs.Job) *importerv1.Job {
+	out := &importerv1.Job{
+		Id:                j.ID,
+		CustomerId:        j.CustomerID,
+		ProductType:       j.ProductType,
+		BlobUri:           j.BlobURI,
+		Status:            statusToPB(j.Status),
+		CreatedAt:         timestamppb.New(j.CreatedAt),
+		UpdatedAt:         timestamppb.New(j.UpdatedAt),
+		StartedAt:         timePB(j.StartedAt),
+		FinishedAt:        timePB(j.FinishedAt),
+		ErrorText:         j.ErrorText,
+		RecordsInserted:   j.RecordsInserted,
+		Attempts:          int32(j.Attempts),
+		MaxAttempts:       int32(j.MaxAttempts),
+		NextRunAt:         timestamppb.New(j.NextRunAt),
+		WorkerId:          j.WorkerID,
+		LeaseExpiresAt:    timePB(j.LeaseExpiresAt),
+		CancelRequestedAt: timePB(j.CancelRequestedAt),
+		Priority:          int32(j.Priority),
+		RunAt:             timePB(j.RunAt),
+		IdempotencyKey:    j.IdempotencyKey,
+		ContentSha256:     j.ContentSHA256,
+		CheckpointBatch:   int32(j.CheckpointBatch),
+		CheckpointRecords: j.CheckpointRecords,
+		CheckpointAt:      timePB(j.CheckpointAt),
+		RecordsRead:       j.RecordsRead,
+		RecordsValid:      j.RecordsValid,
+		RecordsInvalid:    j.RecordsInvalid,
+		RecordsResumed:    j.RecordsResumed,
+		BytesRead:         j.BytesRead,
+		BytesTotal:        j.BytesTotal,
+	}
+	if p := j.Progress; p != nil {
+		out.Progress = &importerv1.Progress{
+			Percent:       p.Percent,
+			RecordsPerSec: p.RecordsPerSec,
+			BytesPerSec:   p.BytesPerSec,
+			EtaSeconds:    p.ETASeconds,
+		}
+	}
+	return out
+}
+
+func logToPB(l *jobs.LogEntry) *importerv1.LogEntry {
+	return &importerv1.LogEntry{
+		Id:          l.ID,
+		JobId:       l.JobID,
+		Level:       l.Level,
+		Message:     l.Message,
+		ContextJson: string(l.Context),
+		CreatedAt:   timestamppb.New(l.CreatedAt),
+	}
+}
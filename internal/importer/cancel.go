@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/jobs"
+)
+
+// ErrCancelled is returned by ProcessJob when the job was cancelled while running.
+var ErrCancelled = errors.New("job cancelled")
+
+// cancelRequested polls the job's cancel flag. Errors count as not requested:
+// an app DB hiccup should not stop an import.
+func (s *Service) cancelRequested(ctx context.Context, jobID int64) bool {
+	requested, _, err := s.JobRepo.CancelRequested(ctx, jobID)
+	if err != nil {
+		fmt.Println("Failed to check cancellation of job", jobID, err)
+		return false
+	}
+	return requested
+}
+
+// stopCancelled finishes a job that stopped on a cancel request, deleting the rows it
+// inserted when the request asked for a rollback. It returns ErrCancelled.
+func (s *Service) stopCancelled(ctx context.Context, cdb *db.CustomerDB, table string, job *jobs.Job) error {
+	// The job context may already be cancelled; the cleanup must still run.
+	ctx = context.WithoutCancel(ctx)
+	_, rollback, err := s.JobRepo.CancelRequested(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("%w; reading rollback flag: %v", ErrCancelled, err)
+	}
+	if !rollback {
+		return ErrCancelled
+	}
+	n, err := cdb.DeleteJobRows(ctx, table, job.ID)
+	if err != nil {
+		s.JobRepo.Log(ctx, job.ID, "error", "rollback of cancelled job failed", []byte(fmt.Sprintf(`{"table":%q,"error":%q}`, table, err.Error())))
+		return fmt.Errorf("%w; rollback failed: %v", ErrCancelled, err)
+	}
+	_ = s.JobRepo.Checkpoint(ctx, job.ID, 0, 0)
+	s.JobRepo.Log(ctx, job.ID, "info", "cancelled job rows rolled back", []byte(fmt.Sprintf(`{"table":%q,"rows_deleted":%d}`, table, n)))
+	return ErrCancelled
+}
+
+// runningJobs tracks the cancel functions of jobs running in this process, so a
+// cancel notification can stop one immediately.
+type runningJobs struct {
+	mu   sync.Mutex
+	jobs map[int64]context.CancelCauseFunc
+}
+
+func newRunningJobs() *runningJobs {
+	return &runningJobs{jobs: map[int64]context.CancelCauseFunc{}}
+}
+
+func (r *runningJobs) add(id int64, cancel context.CancelCauseFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[id] = cancel
+}
+
+func (r *runningJobs) remove(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.jobs, id)
+}
+
+// cancel cancels the job's context with cause, if the job runs here.
+func (r *runningJobs) cancel(id int64, cause error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancel, ok := r.jobs[id]; ok {
+		cancel(cause)
+	}
+}
@@ -0,0 +1,41 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/jobs"
+	"github.com/user/importer/internal/parser"
+)
+
+// resumePoint decides where a job starts given the rows an earlier attempt committed.
+// Batches are committed atomically and in order, so the job's row count in the target
+// table is exactly the number of source records already imported; it is trusted over
+// the job's checkpoint, which is written at most once per progress interval and may
+// lag behind it.
+// Formats that cannot skip records start over, after the earlier rows are deleted.
+func (s *Service) resumePoint(ctx context.Context, cdb *db.CustomerDB, table string, job *jobs.Job, batchSize int) (skip int64, batch int, err error) {
+	committed, err := cdb.CountJobRows(ctx, table, job.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count committed rows: %w", err)
+	}
+	if committed == 0 {
+		return 0, 0, nil
+	}
+	if !parser.Resumable(blobFilename(job.BlobURI)) {
+		n, err := cdb.DeleteJobRows(ctx, table, job.ID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to delete rows of the previous attempt: %w", err)
+		}
+		s.JobRepo.Log(ctx, job.ID, "info", "format cannot resume, rows of the previous attempt deleted", []byte(fmt.Sprintf(`{"table":%q,"rows_deleted":%d}`, table, n)))
+		return 0, 0, nil
+	}
+	batch = job.CheckpointBatch
+	if gap := committed - job.CheckpointRecords; gap > 0 {
+		// full batches committed after the last checkpoint write
+		batch += int((gap + int64(batchSize) - 1) / int64(batchSize))
+	}
+	s.JobRepo.Log(ctx, job.ID, "info", "resuming from checkpoint", []byte(fmt.Sprintf(`{"skip_records":%d,"checkpoint_records":%d,"checkpoint_batch":%d}`, committed, job.CheckpointRecords, job.CheckpointBatch)))
+	return committed, batch, nil
+}
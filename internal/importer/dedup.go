@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/jobs"
+)
+
+// Enqueue creates a job. A request repeating an idempotency key returns the key's job.
+// A URI the blob reader does not serve is refused with blob.ErrUnsupportedScheme, a
+// local path outside the customer's file roots with blob.ErrOutsideRoots. The blob is
+// not read here: with DedupContent, its content is checked while the job imports it.
+func (s *Service) Enqueue(ctx context.Context, customerID, productType, blobURI string, opts jobs.EnqueueOptions) (int64, error) {
+	if opts.IdempotencyKey != "" {
+		if id, ok, err := s.JobRepo.IdempotentJob(ctx, customerID, productType, blobURI, opts.IdempotencyKey); err != nil || ok {
+			return id, err
+		}
+	}
+	if c, ok := s.BlobReader.(blob.Checker); ok {
+		if err := c.Check(blob.WithCustomer(ctx, customerID), blobURI); err != nil {
+			return 0, err
+		}
+	}
+	return s.JobRepo.Enqueue(ctx, customerID, productType, blobURI, opts)
+}
+
+// refuseDuplicate fails the job when content with the given hash was already
+// imported by another job.
+func (s *Service) refuseDuplicate(ctx context.Context, job *jobs.Job, sum string) error {
+	dup, ok, err := s.JobRepo.ImportedContent(ctx, job.CustomerID, job.ProductType, sum, job.ID)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return Permanent(&jobs.DuplicateContentError{JobID: dup})
+	}
+	return nil
+}
+
+// finishContent records the hash of the job's blob, computed while it was parsed.
+// With DedupContent, content whose hash was not known at enqueue (or changed since)
+// is checked now; a duplicate has the rows this job inserted deleted and fails the job.
+func (s *Service) finishContent(ctx context.Context, cdb *db.CustomerDB, table string, job *jobs.Job, sum string) error {
+	if s.DedupContent && sum != job.ContentSHA256 {
+		if err := s.refuseDuplicate(ctx, job, sum); err != nil {
+			var dup *jobs.DuplicateContentError
+			if errors.As(err, &dup) {
+				if _, derr := cdb.DeleteJobRows(ctx, table, job.ID); derr != nil {
+					return fmt.Errorf("%w; deleting imported rows: %v", err, derr)
+				}
+				_ = s.JobRepo.Checkpoint(ctx, job.ID, 0, 0)
+			}
+			return err
+		}
+	}
+	if sum == job.ContentSHA256 {
+		return nil
+	}
+	if err := s.JobRepo.SetContentSHA256(ctx, job.ID, sum); err != nil {
+		if s.DedupContent {
+			return err
+		}
+		fmt.Println("Failed to record content hash:", err)
+	}
+	return nil
+}
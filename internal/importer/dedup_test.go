@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/importer/internal/jobs"
+)
+
+func TestEnqueueDoesNotReadBlob(t *testing.T) {
+	ctx := context.Background()
+	br := newMemBlob(map[string]string{"/in/users.csv": usersCSV})
+	s := newTestService(t, br)
+	s.DedupContent = true
+	opts := jobs.EnqueueOptions{IdempotencyKey: "k1"}
+
+	id, err := s.Enqueue(ctx, "c1", "users", "/in/users.csv", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Enqueue(ctx, "c1", "users", "/in/users.csv", opts)
+	if err != nil || again != id {
+		t.Fatalf("repeated Enqueue = %d, %v; want job %d", again, err, id)
+	}
+	if n := br.openCount("/in/users.csv"); n != 0 {
+		t.Fatalf("blob opened %d times by Enqueue, want none", n)
+	}
+}
+
+func TestProcessJobHashesWhileImporting(t *testing.T) {
+	ctx := context.Background()
+	br := newMemBlob(map[string]string{"/in/a.csv": usersCSV, "/in/b.csv": usersCSV})
+	s := newTestService(t, br)
+	s.DedupContent = true
+	for _, uri := range []string{"/in/a.csv", "/in/b.csv"} {
+		if _, err := s.Enqueue(ctx, "c1", "users", uri, jobs.EnqueueOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := runClaimed(t, s)
+	if err != nil {
+		t.Fatalf("first job: %v", err)
+	}
+	if n := br.openCount(first.BlobURI); n != 1 {
+		t.Fatalf("%s opened %d times, want once", first.BlobURI, n)
+	}
+	if j, _ := s.JobRepo.Get(ctx, first.ID); j.ContentSHA256 == "" {
+		t.Fatal("content hash of the first job not recorded")
+	}
+
+	second, err := runClaimed(t, s)
+	var dup *jobs.DuplicateContentError
+	if !errors.As(err, &dup) || dup.JobID != first.ID || Retryable(err) {
+		t.Fatalf("second job: err = %v, want a permanent duplicate of job %d", err, first.ID)
+	}
+	if n := br.openCount(second.BlobURI); n != 1 {
+		t.Fatalf("%s opened %d times, want once", second.BlobURI, n)
+	}
+	if n := countRows(t, s, "users", second.ID); n != 0 {
+		t.Fatalf("duplicate job left %d rows, want 0", n)
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/parser"
+)
+
+// Retry backoff defaults, used when the Service fields are zero.
+const (
+	DefaultRetryBaseDelay = 30 * time.Second
+	DefaultRetryMaxDelay  = time.Hour
+)
+
+// PermanentError marks a job failure that running the job again cannot fix,
+// such as invalid input. Such jobs fail immediately instead of being retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err as a PermanentError.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Retryable reports whether a failed job may succeed if it runs again. Failures are
+// retryable unless marked permanent or caused by the input itself: a missing blob,
+// malformed or unsupported file content, or data the customer DB rejects.
+func Retryable(err error) bool {
+	var perm *PermanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, parser.ErrUnsupportedFormat) ||
+		errors.Is(err, blob.ErrUnsupportedScheme) || errors.Is(err, blob.ErrTooLarge) || errors.Is(err, blob.ErrOutsideRoots) {
+		return false
+	}
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && len(pgErr.Code) >= 2 {
+		switch pgErr.Code[:2] {
+		case "22", "23", "42": // data exception, integrity violation, syntax/access rule
+			return false
+		}
+	}
+	return true
+}
+
+// retryDelay returns the exponential backoff before the given attempt's retry, with
+// jitter over the upper half so workers retrying together spread out.
+func (s *Service) retryDelay(attempt int) time.Duration {
+	base, max := s.RetryBaseDelay, s.RetryMaxDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxDelay
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,70 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/parser"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		base, max time.Duration
+		attempt   int
+		// want is the delay before jitter; retryDelay returns one in [want/2, want]
+		want time.Duration
+	}{
+		{0, 0, 1, DefaultRetryBaseDelay},
+		{0, 0, 2, 2 * DefaultRetryBaseDelay},
+		{0, 0, 50, DefaultRetryMaxDelay},
+		{time.Second, time.Minute, 0, time.Second},
+		{time.Second, time.Minute, 1, time.Second},
+		{time.Second, time.Minute, 4, 8 * time.Second},
+		{time.Second, time.Minute, 6, 32 * time.Second},
+		{time.Second, time.Minute, 7, time.Minute},
+		{time.Second, time.Minute, 1000, time.Minute},
+		{time.Minute, time.Second, 1, time.Second},
+	}
+	for _, tt := range tests {
+		s := &Service{RetryBaseDelay: tt.base, RetryMaxDelay: tt.max}
+		for i := 0; i < 100; i++ {
+			if d := s.retryDelay(tt.attempt); d < tt.want/2 || d > tt.want {
+				t.Fatalf("retryDelay(%d) with base %s, max %s = %s, want in [%s, %s]", tt.attempt, tt.base, tt.max, d, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{fmt.Errorf("open: %w", fs.ErrNotExist), false},
+		{Permanent(errors.New("bad input")), false},
+		{fmt.Errorf("job 1: %w", Permanent(errors.New("bad input"))), false},
+		{parser.ErrUnsupportedFormat, false},
+		{blob.ErrUnsupportedScheme, false},
+		{blob.ErrTooLarge, false},
+		{blob.ErrOutsideRoots, false},
+		{&csv.ParseError{Line: 3, Err: csv.ErrFieldCount}, false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "22P02"}, false},
+		{&pgconn.PgError{Code: "42703"}, false},
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
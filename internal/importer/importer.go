@@ -2,13 +2,21 @@ package importer
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/config"
 	"github.com/user/importer/internal/db"
 	"github.com/user/importer/internal/jobs"
 	"github.com/user/importer/internal/parser"
@@ -20,11 +28,40 @@ import (
 type Service struct {
 	BlobReader blob.Reader
 	JobRepo    *jobs.Repository
-	CustMap    map[string]string
+	CustMap    config.CustomerDBMap
+	// Backoff for retryable failures: RetryBaseDelay doubles per attempt up to RetryMaxDelay.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// WorkerID identifies this process in job leases.
+	WorkerID string
+	// LeaseTTL is how long a job's lease lasts without a heartbeat.
+	LeaseTTL time.Duration
+	// ReapInterval is how often expired leases are looked for.
+	ReapInterval time.Duration
+	// PollInterval is the fallback poll for job notifications missed by idle workers.
+	PollInterval time.Duration
+	// ProgressInterval is the minimum time between progress writes of a running job.
+	ProgressInterval time.Duration
+	// DedupContent refuses jobs whose blob content was already imported successfully
+	// for the same customer and product type.
+	DedupContent bool
+	// BlobWriter stores uploaded files under UploadStagingURI, a directory or URI prefix.
+	BlobWriter       blob.Writer
+	UploadStagingURI string
+	// MaxUploadBytes caps the size of an uploaded file; zero means no cap.
+	MaxUploadBytes int64
+
+	running *runningJobs
 }
 
-func NewService(br blob.Reader, jr *jobs.Repository, cust map[string]string) *Service {
-	return &Service{BlobReader: br, JobRepo: jr, CustMap: cust}
+// Dispatch defaults, used when the Service fields are zero.
+const (
+	DefaultPollInterval = 2 * time.Minute
+	fetchErrorDelay     = 5 * time.Second
+)
+
+func NewService(br blob.Reader, jr *jobs.Repository, cust config.CustomerDBMap) *Service {
+	return &Service{BlobReader: br, JobRepo: jr, CustMap: cust, WorkerID: newWorkerID(), running: newRunningJobs()}
 }
 
 // ProcessJob executes a single job end-to-end.
@@ -49,19 +86,33 @@ func (s *Service) ProcessJob(ctx context.Context, job *jobs.Job) error {
 	}
 
 	if err := products.ValidateProductType(job.ProductType); err != nil {
-		return err
+		return Permanent(err)
 	}
-	dsn, ok := s.CustMap[job.CustomerID]
+	target, ok := s.CustMap[job.CustomerID]
 	if !ok {
-		return fmt.Errorf("unknown customer id: %s", job.CustomerID)
+		return Permanent(fmt.Errorf("unknown customer id: %s", job.CustomerID))
+	}
+	// blob readers with per-customer credentials pick them from ctx
+	ctx = blob.WithCustomer(ctx, job.CustomerID)
+	// Content whose hash is known at enqueue, like an upload's, can be refused before
+	// it is read; otherwise the hash computed while parsing is checked once the blob
+	// is through.
+	if s.DedupContent && job.ContentSHA256 != "" {
+		if err := s.refuseDuplicate(ctx, job, job.ContentSHA256); err != nil {
+			return err
+		}
 	}
 	rc, err := s.BlobReader.Open(ctx, job.BlobURI)
 	if err != nil {
 		return fmt.Errorf("failed to open blob %s: %w", job.BlobURI, err)
 	}
 	defer rc.Close()
+	// Hash while parsing, so later jobs can be checked against this content.
+	src := &countingReader{r: rc}
+	h := sha256.New()
+	body := io.TeeReader(src, h)
 
-	cdb, err := db.ConnectCustomerDB(ctx, dsn)
+	cdb, err := db.ConnectCustomerDB(ctx, target.DSN, target.Schema)
 	if err != nil {
 		return fmt.Errorf("failed to connect to customer db: %w", err)
 	}
@@ -71,8 +122,12 @@ func (s *Service) ProcessJob(ctx context.Context, job *jobs.Job) error {
 	if err != nil {
 		return fmt.Errorf("failed to get target table for product type %s: %w", job.ProductType, err)
 	}
-	if err := cdb.EnsureTargetTable(ctx, table); err != nil {
-		return fmt.Errorf("failed to ensure target table %s: %w", table, err)
+	applied, err := cdb.MigrateForImport(ctx, job.ProductType)
+	if err != nil {
+		return fmt.Errorf("failed to migrate target table %s: %w", table, err)
+	}
+	for _, m := range applied {
+		s.JobRepo.Log(ctx, job.ID, "info", "target table migration applied", []byte(fmt.Sprintf(`{"table":%q,"version":%d,"name":%q}`, table, m.Version, m.Name)))
 	}
 
 	s.JobRepo.Log(ctx, job.ID, "info", "target table ensured", []byte(fmt.Sprintf(`{"table": %s}`, table)))
@@ -84,33 +139,71 @@ func (s *Service) ProcessJob(ctx context.Context, job *jobs.Job) error {
 		}
 	}
 
-	processed := 0
+	skip, batchNo, err := s.resumePoint(ctx, cdb, table, job, batchSize)
+	if err != nil {
+		return err
+	}
+	prog := s.newProgress(ctx, job, src, batchNo, skip)
+	prog.flush(ctx, true)
+
+	processed := int(skip)
 	handler := func(records []parser.Record) error {
+		if s.cancelRequested(ctx, job.ID) {
+			return ErrCancelled
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		prog.p.RecordsRead += int64(len(records))
 		if err := validate.Records(job.ProductType, records); err != nil {
-			return err
+			invalid := validate.CountInvalid(job.ProductType, records)
+			prog.p.RecordsInvalid += int64(invalid)
+			prog.p.RecordsValid += int64(len(records) - invalid)
+			return Permanent(err)
 		}
+		prog.p.RecordsValid += int64(len(records))
 		fmt.Printf("Inserting batch of %d records into customer: %s, table: %s\n", len(records), job.CustomerID, table)
 
+		docs := make([][]byte, 0, len(records))
 		for _, rec := range records {
 			b, err := json.Marshal(rec)
 			if err != nil {
 				return err
 			}
-			if err := cdb.InsertJSONB(ctx, table, b); err != nil {
-				return err
-			}
-			processed++
+			docs = append(docs, b)
 		}
+		if err := cdb.InsertBatch(ctx, table, job.ID, docs); err != nil {
+			return err
+		}
+		processed += len(records)
+		prog.p.Batch++
+		prog.p.RecordsInserted = int64(processed)
+		prog.flush(ctx, false)
 		return nil
 	}
 
 	fmt.Printf("Starting to parse blob %s for customer %s, product type %s\n", job.BlobURI, job.CustomerID, job.ProductType)
 
-	if err := parser.ParseBatches(filepath.Base(job.BlobURI), rc, batchSize, handler); err != nil {
+	err = parser.ParseBatchesFrom(blobFilename(job.BlobURI), body, batchSize, skip, handler)
+	// final counts, also when the job context was cancelled
+	prog.flush(context.WithoutCancel(ctx), true)
+	if errors.Is(err, ErrCancelled) || errors.Is(context.Cause(ctx), ErrCancelled) {
+		return s.stopCancelled(ctx, cdb, table, job)
+	}
+	if err != nil {
 		s.JobRepo.Log(ctx, job.ID, "error", "job failed during parsing/processing", []byte(fmt.Sprintf(`{"error":"%v"}`, err.Error())))
 		return fmt.Errorf("failed to parse/process blob %s: %w", job.BlobURI, err)
 	}
 
+	// the parser may stop before EOF, e.g. at a trailing blank line
+	if _, err := io.Copy(io.Discard, body); err == nil {
+		if err := s.finishContent(ctx, cdb, table, job, hex.EncodeToString(h.Sum(nil))); err != nil {
+			return err
+		}
+	} else if s.DedupContent {
+		return fmt.Errorf("failed to hash blob %s: %w", job.BlobURI, err)
+	}
+
 	completedAt := time.Now()
 	logMsg := fmt.Sprintf(`{"processed_records":"` + strconv.Itoa(processed) + `", "completed_at": "` + completedAt.Format(time.RFC3339) + `", duration_sec: "` + strconv.FormatFloat(completedAt.Sub(startedAt).Seconds(), 'f', 2, 64) + `"}`)
 	logMsgBytes, err = json.Marshal(logMsg)
@@ -127,58 +220,164 @@ func (s *Service) ProcessJob(ctx context.Context, job *jobs.Job) error {
 	return nil
 }
 
-// Worker consumes jobs concurrently.
+// runJob processes a claimed job while renewing its lease, then records the outcome.
+// A job whose lease was lost belongs to whoever reclaimed it, so nothing is recorded.
+func (s *Service) runJob(ctx context.Context, j *jobs.Job) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	s.running.add(j.ID, cancel)
+	defer s.running.remove(j.ID)
+	hbDone := make(chan struct{})
+	go func() {
+		defer close(hbDone)
+		s.heartbeat(jobCtx, j, cancel)
+	}()
+
+	err := s.ProcessJob(jobCtx, j)
+	cancel(nil)
+	<-hbDone
+
+	if errors.Is(context.Cause(jobCtx), ErrLeaseLost) {
+		s.abandon(j)
+		return
+	}
+	var rerr error
+	switch {
+	case errors.Is(err, ErrCancelled) || (err != nil && errors.Is(context.Cause(jobCtx), ErrCancelled)):
+		rerr = s.JobRepo.MarkCancelled(ctx, j.ID, s.WorkerID)
+	case err != nil:
+		rerr = s.handleFailure(ctx, j, err)
+	default:
+		rerr = s.JobRepo.Complete(ctx, j.ID, s.WorkerID)
+	}
+	// The lease can also expire between the last heartbeat and recording the outcome.
+	if errors.Is(rerr, ErrLeaseLost) {
+		s.abandon(j)
+	} else if rerr != nil {
+		fmt.Println("Failed to record outcome of job", j.ID, rerr)
+	}
+}
+
+// abandon drops the result of a job whose lease was lost; it belongs to whoever
+// reclaimed it.
+func (s *Service) abandon(j *jobs.Job) {
+	fmt.Printf("Job %d: lease lost by worker %s, abandoning\n", j.ID, s.WorkerID)
+}
+
+// handleFailure retries a failed job with backoff when the error is retryable,
+// and fails it for good otherwise.
+func (s *Service) handleFailure(ctx context.Context, j *jobs.Job, err error) error {
+	if !Retryable(err) {
+		return s.JobRepo.Fail(ctx, j.ID, s.WorkerID, err.Error())
+	}
+	st, rerr := s.JobRepo.Retry(ctx, j.ID, s.WorkerID, err.Error(), s.retryDelay(j.Attempts))
+	if rerr != nil {
+		return rerr
+	}
+	fmt.Printf("Job %d attempt %d/%d failed: %v (now %s)\n", j.ID, j.Attempts, j.MaxAttempts, err, st)
+	return nil
+}
+
+// Worker consumes jobs concurrently. Idle workers wait for a NOTIFY from Enqueue (or
+// for the next retry or scheduled job to become due) instead of polling; a slow
+// fallback poll covers notifications missed while the listener reconnects. A job
+// enqueued to run later notifies too, so a woken worker recomputes its sleep from
+// NextRunAt and starts the job on time.
 func (s *Service) Worker(ctx context.Context, concurrency int) {
 	if concurrency <= 0 {
 		concurrency = 1
 	}
-	jobCh := make(chan *jobs.Job)
-	done := make(chan struct{})
+	// One token per notification, at most one per worker.
+	wake := make(chan struct{}, concurrency)
+	signal := func() {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
 
-	for i := 0; i < concurrency; i++ {
-		go func() {
-			for j := range jobCh {
-				if j == nil { // no job, continue polling
-					time.Sleep(60 * time.Second)
-					continue
-				}
-				if err := s.ProcessJob(ctx, j); err != nil {
-					_ = s.JobRepo.Fail(ctx, j.ID, err.Error())
-				} else {
-					_ = s.JobRepo.Complete(ctx, j.ID)
-				}
+	go s.JobRepo.Listen(ctx, []string{jobs.ReadyChannel, jobs.CancelChannel}, signal, func(channel, payload string) {
+		switch channel {
+		case jobs.ReadyChannel:
+			signal()
+		case jobs.CancelChannel:
+			if id, err := strconv.ParseInt(payload, 10, 64); err == nil {
+				s.running.cancel(id, ErrCancelled)
 			}
+		}
+	})
+	go s.reap(ctx)
 
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.workLoop(ctx, wake)
 			fmt.Println("worker done")
-			done <- struct{}{}
 		}()
 	}
+	// Wait for workers to exit when context is cancelled
+	wg.Wait()
+}
 
-	// Poll loop
-	go func() {
-		defer close(jobCh)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				j, err := s.JobRepo.FetchAndStart(ctx)
-				if err != nil {
-					// best-effort log
-					_ = s.JobRepo.Log(ctx, 0, "error", "FetchAndStart failed", []byte(`{"error":"`+err.Error()+`"}`))
-					continue
-				}
-
-				// got a job
-				fmt.Println("Fetched job:", j)
-
-				jobCh <- j
-			}
+// workLoop runs jobs back to back while any are eligible, and otherwise sleeps until woken.
+func (s *Service) workLoop(ctx context.Context, wake <-chan struct{}) {
+	for ctx.Err() == nil {
+		j, err := s.JobRepo.FetchAndStart(ctx, s.WorkerID, s.leaseTTL())
+		if err != nil {
+			fmt.Println("FetchAndStart failed:", err)
+			sleepCtx(ctx, fetchErrorDelay)
+			continue
+		}
+		if j != nil {
+			fmt.Println("Fetched job:", j.ID)
+			s.runJob(ctx, j)
+			continue
 		}
-	}()
 
-	// Wait for workers to exit when context is cancelled
-	for i := 0; i < concurrency; i++ {
-		<-done
+		t := time.NewTimer(s.idleWait(ctx))
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+// idleWait is how long an idle worker sleeps when no notification arrives: until the
+// next queued job becomes due, capped by the fallback poll interval.
+func (s *Service) idleWait(ctx context.Context) time.Duration {
+	wait := s.PollInterval
+	if wait <= 0 {
+		wait = DefaultPollInterval
+	}
+	at, ok, err := s.JobRepo.NextRunAt(ctx)
+	if err != nil || !ok {
+		return wait
+	}
+	if d := time.Until(at); d < wait {
+		// never spin: a due job another worker holds locked is retried shortly
+		return max(d, time.Second)
+	}
+	return wait
+}
+
+// blobFilename is the file name of a blob URI, whose extension selects the parser. For
+// URLs it comes from the path, so a query string such as an Azure SAS token is ignored.
+func blobFilename(uri string) string {
+	// a one-letter scheme is a Windows drive
+	if u, err := url.Parse(uri); err == nil && len(u.Scheme) > 1 {
+		return path.Base(u.Path)
+	}
+	return filepath.Base(uri)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
 }
@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/config"
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/db/dbtest"
+	"github.com/user/importer/internal/jobs"
+)
+
+const usersCSV = "id,name,lastname,middlename,email,orgid\n1,foo1,foo,foo,foo1@foo.com,1\n2,foo2,foo,foo,foo2@foo.com,2\n"
+
+// memBlob stores blobs in memory and counts how often each is opened.
+type memBlob struct {
+	mu    sync.Mutex
+	blobs map[string]string
+	opens map[string]int
+}
+
+func newMemBlob(blobs map[string]string) *memBlob {
+	return &memBlob{blobs: blobs, opens: map[string]int{}}
+}
+
+func (m *memBlob) Open(ctx context.Context, uri string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opens[uri]++
+	s, ok := m.blobs[uri]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(s)), nil
+}
+
+func (m *memBlob) Create(ctx context.Context, uri string) (io.WriteCloser, error) {
+	return &memWriter{m: m, uri: uri}, nil
+}
+
+func (m *memBlob) Delete(ctx context.Context, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, uri)
+	return nil
+}
+
+func (m *memBlob) has(uri string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.blobs[uri]
+	return ok
+}
+
+func (m *memBlob) openCount(uri string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.opens[uri]
+}
+
+type memWriter struct {
+	m   *memBlob
+	uri string
+	buf strings.Builder
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *memWriter) Close() error {
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	w.m.blobs[w.uri] = w.buf.String()
+	return nil
+}
+
+// newTestService returns a Service on a fresh app DB, importing customer c1 into a
+// fresh schema.
+func newTestService(t *testing.T, br blob.Reader) *Service {
+	dsn, schema := dbtest.CustomerTarget(t)
+	jr := jobs.NewRepository(dbtest.AppDB(t))
+	return NewService(br, jr, config.CustomerDBMap{"c1": {DSN: dsn, Schema: schema}})
+}
+
+// runClaimed claims the next job as the service's worker and processes it, recording
+// success.
+func runClaimed(t *testing.T, s *Service) (*jobs.Job, error) {
+	t.Helper()
+	ctx := context.Background()
+	j, err := s.JobRepo.FetchAndStart(ctx, s.WorkerID, time.Minute)
+	if err != nil || j == nil {
+		t.Fatalf("FetchAndStart = %v, %v", j, err)
+	}
+	if err := s.ProcessJob(ctx, j); err != nil {
+		return j, err
+	}
+	if err := s.JobRepo.Complete(ctx, j.ID, s.WorkerID); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	return j, nil
+}
+
+func countRows(t *testing.T, s *Service, table string, jobID int64) int64 {
+	t.Helper()
+	ctx := context.Background()
+	n, err := mustCustomerDB(t, s).CountJobRows(ctx, table, jobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestIdleWaitUntilScheduledJob(t *testing.T) {
+	ctx := context.Background()
+	jr := jobs.NewRepository(dbtest.AppDB(t))
+	s := NewService(nil, jr, nil)
+	s.PollInterval = time.Hour
+
+	if got := s.idleWait(ctx); got != time.Hour {
+		t.Fatalf("idleWait with no jobs = %s, want the poll interval", got)
+	}
+	if _, err := jr.Enqueue(ctx, "c1", "users", "/data/users.csv", jobs.EnqueueOptions{RunAt: time.Now().Add(10 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.idleWait(ctx); got > 10*time.Second || got < 5*time.Second {
+		t.Fatalf("idleWait = %s, want about 10s until the scheduled job", got)
+	}
+}
+
+func TestProcessJobURLWithQuery(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/export/users.csv" || r.URL.Query().Get("token") != "t/1=" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, usersCSV)
+	}))
+	defer srv.Close()
+	s := newTestService(t, &blob.HTTPBlob{})
+	if _, err := s.JobRepo.Enqueue(ctx, "c1", "users", srv.URL+"/export/users.csv?token=t%2F1%3D", jobs.EnqueueOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := runClaimed(t, s)
+	if err != nil {
+		t.Fatalf("ProcessJob: %v", err)
+	}
+	if n := countRows(t, s, "users", j.ID); n != 2 {
+		t.Fatalf("imported %d rows, want 2", n)
+	}
+}
+
+// azureTransport sends https://<account>.blob.core.windows.net requests to a test server.
+type azureTransport struct{ addr string }
+
+func (rt azureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = "http", rt.addr
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestProcessJobAzureSASURL(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/exports/users.csv" || r.URL.Query().Get("sig") != "a/b=" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, usersCSV)
+	}))
+	defer srv.Close()
+	reg := blob.NewRegistry()
+	az := &blob.AzureBlob{Client: &http.Client{Transport: azureTransport{addr: srv.Listener.Addr().String()}}}
+	reg.Register("az", az)
+	reg.RegisterHost("https", ".blob.core.windows.net", az)
+	s := newTestService(t, reg)
+
+	uri := "https://partner.blob.core.windows.net/exports/users.csv?sv=2022-11-02&sr=b&se=2030-01-01T00%3A00%3A00Z&sp=r&sig=a%2Fb%3D"
+	id, err := s.Enqueue(ctx, "c1", "users", uri, jobs.EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := runClaimed(t, s)
+	if err != nil {
+		t.Fatalf("ProcessJob: %v", err)
+	}
+	if j.ID != id {
+		t.Fatalf("ran job %d, want %d", j.ID, id)
+	}
+	if n := countRows(t, s, "users", id); n != 2 {
+		t.Fatalf("imported %d rows, want 2", n)
+	}
+	// a retry resumes, which needs the format from the same file name
+	if skip, _, err := s.resumePoint(ctx, mustCustomerDB(t, s), "users", j, 1000); err != nil || skip != 2 {
+		t.Fatalf("resumePoint = %d, %v; want to skip the 2 committed records", skip, err)
+	}
+}
+
+func mustCustomerDB(t *testing.T, s *Service) *db.CustomerDB {
+	t.Helper()
+	target := s.CustMap["c1"]
+	cdb, err := db.ConnectCustomerDB(context.Background(), target.DSN, target.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cdb.Pool.Close)
+	return cdb
+}
+
+func TestBlobFilename(t *testing.T) {
+	tests := []struct{ uri, want string }{
+		{"/data/users.csv", "users.csv"},
+		{"data/users.tsv", "users.tsv"},
+		{"file:///data/users.csv", "users.csv"},
+		{"s3://bucket/in/users.csv", "users.csv"},
+		{"s3://bucket/in/my%20users.csv", "my users.csv"},
+		{"https://acct.blob.core.windows.net/c/users.csv?sv=2022-11-02&sig=abc%2Fdef%3D", "users.csv"},
+		{"https://example.com/export.tsv?token=x#part", "export.tsv"},
+		{"sftp://user@host/home/u/users.csv", "users.csv"},
+		{"/data/what?.csv", "what?.csv"},
+	}
+	for _, tt := range tests {
+		if got := blobFilename(tt.uri); got != tt.want {
+			t.Errorf("blobFilename(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/importer/internal/jobs"
+)
+
+// Lease defaults, used when the Service fields are zero.
+const (
+	DefaultLeaseTTL     = 60 * time.Second
+	DefaultReapInterval = 30 * time.Second
+)
+
+// ErrLeaseLost cancels a job's context when its worker no longer holds the lease.
+var ErrLeaseLost = jobs.ErrLeaseLost
+
+// newWorkerID identifies this process in job leases: host, pid and a random suffix,
+// so restarted processes with a recycled pid are still distinct.
+func newWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+func (s *Service) leaseTTL() time.Duration {
+	if s.LeaseTTL <= 0 {
+		return DefaultLeaseTTL
+	}
+	return s.LeaseTTL
+}
+
+// heartbeat renews the job's lease every third of the lease TTL until ctx is done.
+// If the lease was taken away it cancels the job with ErrLeaseLost.
+func (s *Service) heartbeat(ctx context.Context, j *jobs.Job, cancel context.CancelCauseFunc) {
+	t := time.NewTicker(s.leaseTTL() / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			held, err := s.JobRepo.Heartbeat(ctx, j.ID, s.WorkerID, s.leaseTTL())
+			if err != nil {
+				// transient; the lease has room for two more beats
+				fmt.Println("Heartbeat failed for job", j.ID, err)
+				continue
+			}
+			if !held {
+				cancel(ErrLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+// reap periodically recovers jobs whose lease expired until ctx is done.
+func (s *Service) reap(ctx context.Context) {
+	interval := s.ReapInterval
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.JobRepo.ReapExpired(ctx)
+			if err != nil {
+				fmt.Println("Reaping expired job leases failed:", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Printf("Recovered %d job(s) with expired leases\n", n)
+			}
+		}
+	}
+}
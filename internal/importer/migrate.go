@@ -0,0 +1,58 @@
+package importer
+
+import (
+	"context"
+	"sort"
+
+	"github.com/user/importer/internal/config"
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/products"
+)
+
+// CustomerMigrationResult reports the target table migrations for one customer.
+// Migrations holds applied migrations, or pending ones on a dry run, by product type.
+type CustomerMigrationResult struct {
+	CustomerID string
+	Migrations map[string][]db.Migration
+	Err        error
+}
+
+// MigrateCustomers applies pending target table migrations for every product in every
+// customer database of the map, in customer id order. A failing customer does not stop
+// the others; its error is reported in its result.
+func MigrateCustomers(ctx context.Context, custMap config.CustomerDBMap, dryRun bool) []CustomerMigrationResult {
+	ids := make([]string, 0, len(custMap))
+	for id := range custMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([]CustomerMigrationResult, 0, len(ids))
+	for _, id := range ids {
+		res := CustomerMigrationResult{CustomerID: id, Migrations: map[string][]db.Migration{}}
+		res.Err = migrateCustomer(ctx, custMap[id], dryRun, res.Migrations)
+		results = append(results, res)
+	}
+	return results
+}
+
+func migrateCustomer(ctx context.Context, target config.CustomerTarget, dryRun bool, out map[string][]db.Migration) error {
+	cdb, err := db.ConnectCustomerDB(ctx, target.DSN, target.Schema)
+	if err != nil {
+		return err
+	}
+	defer cdb.Pool.Close()
+	for _, pt := range products.Types() {
+		var migs []db.Migration
+		if dryRun {
+			migs, err = cdb.PendingMigrations(ctx, pt)
+		} else {
+			migs, err = cdb.Migrate(ctx, pt)
+		}
+		out[pt] = migs
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/jobs"
+)
+
+// DefaultProgressInterval is the minimum time between progress writes of a running job.
+const DefaultProgressInterval = 2 * time.Second
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// progressTracker accumulates a job's progress batch by batch and writes it to the app
+// DB at most once per interval, so small batches do not turn into a write each.
+type progressTracker struct {
+	repo     *jobs.Repository
+	jobID    int64
+	interval time.Duration
+	src      *countingReader
+	p        jobs.Progress
+	last     time.Time
+}
+
+// newProgress starts tracking a job that resumes after skip committed records.
+func (s *Service) newProgress(ctx context.Context, job *jobs.Job, src *countingReader, batch int, skip int64) *progressTracker {
+	t := &progressTracker{repo: s.JobRepo, jobID: job.ID, interval: s.ProgressInterval, src: src}
+	if t.interval <= 0 {
+		t.interval = DefaultProgressInterval
+	}
+	t.p = jobs.Progress{Batch: batch, RecordsRead: skip, RecordsValid: skip, RecordsInserted: skip, RecordsResumed: skip}
+	if sz, ok := s.BlobReader.(blob.Sizer); ok {
+		if n, err := sz.Size(ctx, job.BlobURI); err == nil {
+			t.p.BytesTotal = n
+		}
+	}
+	return t
+}
+
+// flush writes the progress when the interval has passed since the last write, or always with force.
+func (t *progressTracker) flush(ctx context.Context, force bool) {
+	if !force && time.Since(t.last) < t.interval {
+		return
+	}
+	t.p.BytesRead = t.src.n
+	if err := t.repo.UpdateProgress(ctx, t.jobID, t.p); err != nil {
+		// progress is informational; committed rows decide where a retry resumes
+		fmt.Println("Failed to record progress of job", t.jobID, err)
+		return
+	}
+	t.last = time.Now()
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/jobs"
+	"github.com/user/importer/internal/products"
+)
+
+var (
+	// ErrInvalidUpload reports upload metadata the importer cannot accept.
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrChecksumMismatch reports uploaded content that does not match the checksum sent with it.
+	ErrChecksumMismatch = errors.New("upload checksum mismatch")
+	// ErrUploadTooLarge reports an upload over the Service's MaxUploadBytes.
+	ErrUploadTooLarge = errors.New("upload too large")
+)
+
+// Upload describes a file streamed to the importer instead of referenced by blob URI.
+type Upload struct {
+	CustomerID  string
+	ProductType string
+	// Filename is the client's name for the file; its extension selects the parser.
+	Filename    string
+	ContentType string
+	// SHA256 is the optional hex checksum the content must match.
+	SHA256   string
+	Priority int
+}
+
+// Upload stores the content read from r under UploadStagingURI and enqueues its import.
+// The job is created only once the content is fully stored and matches u.SHA256. The
+// upload is recorded in the app DB together with its job; when the job cannot be
+// enqueued, nothing is recorded and the staged blob is deleted again.
+func (s *Service) Upload(ctx context.Context, u Upload, r io.Reader) (*jobs.Upload, error) {
+	if s.BlobWriter == nil || s.UploadStagingURI == "" {
+		return nil, errors.New("uploads are not configured")
+	}
+	if err := s.validateUpload(u); err != nil {
+		return nil, err
+	}
+	uri, err := s.stagingURI(u)
+	if err != nil {
+		return nil, err
+	}
+	if s.MaxUploadBytes > 0 {
+		// one byte over the limit tells a too large upload from one of exactly the limit
+		r = io.LimitReader(r, s.MaxUploadBytes+1)
+	}
+
+	// cancelling wctx before Close discards the partly written blob
+	wctx, cancel := context.WithCancel(blob.WithCustomer(ctx, u.CustomerID))
+	defer cancel()
+	w, err := s.BlobWriter.Create(wctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err == nil && s.MaxUploadBytes > 0 && n > s.MaxUploadBytes {
+		err = fmt.Errorf("%w: limit is %d bytes", ErrUploadTooLarge, s.MaxUploadBytes)
+	}
+	if err == nil && u.SHA256 != "" && !strings.EqualFold(u.SHA256, sum) {
+		err = fmt.Errorf("%w: got %s", ErrChecksumMismatch, sum)
+	}
+	if err != nil {
+		cancel()
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+
+	rec := &jobs.Upload{
+		CustomerID:  u.CustomerID,
+		ProductType: u.ProductType,
+		Filename:    u.Filename,
+		ContentType: u.ContentType,
+		BlobURI:     uri,
+		SizeBytes:   n,
+		SHA256:      sum,
+	}
+	opts := jobs.EnqueueOptions{Priority: u.Priority}
+	if s.DedupContent {
+		opts.ContentSHA256 = sum
+	}
+	if err := s.JobRepo.EnqueueUpload(ctx, rec, opts); err != nil {
+		s.deleteStaged(ctx, uri)
+		return nil, err
+	}
+	return rec, nil
+}
+
+// deleteStaged removes a staged upload that no job will import.
+func (s *Service) deleteStaged(ctx context.Context, uri string) {
+	d, ok := s.BlobWriter.(blob.Deleter)
+	if !ok {
+		return
+	}
+	if err := d.Delete(context.WithoutCancel(ctx), uri); err != nil {
+		fmt.Println("Failed to delete staged upload:", uri, err)
+	}
+}
+
+func (s *Service) validateUpload(u Upload) error {
+	if u.CustomerID == "" || u.ProductType == "" || u.Filename == "" {
+		return fmt.Errorf("%w: customer_id, product_type, and filename are required", ErrInvalidUpload)
+	}
+	if _, ok := s.CustMap[u.CustomerID]; !ok {
+		return fmt.Errorf("%w: unknown customer id: %s", ErrInvalidUpload, u.CustomerID)
+	}
+	if err := products.ValidateProductType(u.ProductType); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+	}
+	if u.SHA256 != "" {
+		if b, err := hex.DecodeString(u.SHA256); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("%w: sha256 must be 64 hex characters", ErrInvalidUpload)
+		}
+	}
+	return nil
+}
+
+// stagingURI places an upload under UploadStagingURI as <customer>/<random>/<filename>,
+// so uploads of the same file name never overwrite each other.
+func (s *Service) stagingURI(u Upload) (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	name := safeName(path.Base(strings.ReplaceAll(u.Filename, `\`, "/")))
+	return strings.TrimSuffix(s.UploadStagingURI, "/") + "/" + path.Join(safeName(u.CustomerID), hex.EncodeToString(b[:]), name), nil
+}
+
+// safeName keeps letters, digits, '.', '-' and '_' and replaces anything else with '_'.
+func safeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(name, ".") == "" {
+		return "upload"
+	}
+	return name
+}
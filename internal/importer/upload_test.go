@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/importer/internal/jobs"
+)
+
+func TestUploadEnqueuesWithRecord(t *testing.T) {
+	ctx := context.Background()
+	br := newMemBlob(map[string]string{})
+	s := newTestService(t, br)
+	s.BlobWriter, s.UploadStagingURI = br, "/staging"
+
+	rec, err := s.Upload(ctx, Upload{CustomerID: "c1", ProductType: "users", Filename: "users.csv"}, strings.NewReader(usersCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.JobID == nil || !br.has(rec.BlobURI) {
+		t.Fatalf("upload = %+v, want a job and a staged blob", rec)
+	}
+	got, err := s.JobRepo.GetUpload(ctx, rec.ID)
+	if err != nil || got.JobID == nil || *got.JobID != *rec.JobID {
+		t.Fatalf("GetUpload = %+v, %v; want job %d", got, err, *rec.JobID)
+	}
+	j, err := s.JobRepo.Get(ctx, *rec.JobID)
+	if err != nil || j.BlobURI != rec.BlobURI {
+		t.Fatalf("Get = %+v, %v; want a job importing %s", j, err, rec.BlobURI)
+	}
+}
+
+func TestUploadNotEnqueuedLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	br := newMemBlob(map[string]string{})
+	s := newTestService(t, br)
+	s.BlobWriter, s.UploadStagingURI = br, "/staging"
+	s.DedupContent = true
+	u := Upload{CustomerID: "c1", ProductType: "users", Filename: "users.csv"}
+
+	first, err := s.Upload(ctx, u, strings.NewReader(usersCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.JobRepo.FetchAndStart(ctx, "w", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.JobRepo.Complete(ctx, *first.JobID, "w"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Upload(ctx, u, strings.NewReader(usersCSV))
+	var dup *jobs.DuplicateContentError
+	if !errors.As(err, &dup) {
+		t.Fatalf("second upload: err = %v, want a duplicate", err)
+	}
+	var uploads int
+	if err := s.JobRepo.DB.Pool.QueryRow(ctx, `SELECT count(*) FROM import_uploads`).Scan(&uploads); err != nil {
+		t.Fatal(err)
+	}
+	if uploads != 1 {
+		t.Fatalf("%d uploads recorded, want only the first", uploads)
+	}
+	br.mu.Lock()
+	staged := len(br.blobs)
+	br.mu.Unlock()
+	if staged != 1 {
+		t.Fatalf("%d blobs staged, want the refused one deleted", staged)
+	}
+}
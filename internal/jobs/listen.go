@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReadyChannel is the Postgres NOTIFY channel signalled when a job becomes runnable,
+// or is queued to become runnable later, so idle workers can reschedule their wake-up.
+const ReadyChannel = "import_job_ready"
+
+// listenRetryDelay is how long Listen waits before reconnecting after an error.
+const listenRetryDelay = 5 * time.Second
+
+// notify sends a Postgres notification; it is delivered when the surrounding
+// transaction (or, outside one, the statement) commits.
+func (r *Repository) notify(ctx context.Context, channel, payload string) error {
+	_, err := r.DB.Pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, payload)
+	return err
+}
+
+// Listen LISTENs on channels over a dedicated connection and calls fn for every
+// notification until ctx is done. After a connection error it reconnects; onConnect,
+// if set, runs after each (re)connect so callers can catch up on anything missed.
+func (r *Repository) Listen(ctx context.Context, channels []string, onConnect func(), fn func(channel, payload string)) {
+	for {
+		err := r.listen(ctx, channels, onConnect, fn)
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Job listener on %v failed: %v; reconnecting in %s\n", channels, err, listenRetryDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (r *Repository) listen(ctx context.Context, channels []string, onConnect func(), fn func(channel, payload string)) error {
+	pc, err := r.DB.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Take the connection out of the pool: it stays LISTENing until closed.
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	for _, ch := range channels {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ch}.Sanitize()); err != nil {
+			return err
+		}
+	}
+	if onConnect != nil {
+		onConnect()
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Channel, n.Payload)
+	}
+}
+
+// NextRunAt returns when the earliest queued job that is not yet eligible (a retry
+// waiting out its backoff, or a job scheduled with run_at) becomes eligible; ok is false
+// when there is none.
+func (r *Repository) NextRunAt(ctx context.Context) (at time.Time, ok bool, err error) {
+	var next *time.Time
+	if err := r.DB.Pool.QueryRow(ctx, `SELECT min(next_run_at) FROM import_jobs WHERE status='queued' AND next_run_at > now()`).Scan(&next); err != nil {
+		return time.Time{}, false, err
+	}
+	if next == nil {
+		return time.Time{}, false, nil
+	}
+	return *next, true, nil
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEnqueueLaterNotifiesAndSetsNextRunAt(t *testing.T) {
+	r := newTestRepo(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connected := make(chan struct{})
+	got := make(chan string, 4)
+	go r.Listen(ctx, []string{ReadyChannel}, func() { close(connected) }, func(_, payload string) { got <- payload })
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not connect")
+	}
+
+	runAt := time.Now().Add(30 * time.Second).Truncate(time.Microsecond)
+	id := enqueue(t, r, "c1", EnqueueOptions{RunAt: runAt})
+	select {
+	case p := <-got:
+		if p != strconv.FormatInt(id, 10) {
+			t.Fatalf("notification payload = %q, want %d", p, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for a job enqueued to run later")
+	}
+	at, ok, err := r.NextRunAt(ctx)
+	if err != nil || !ok || !at.Equal(runAt) {
+		t.Fatalf("NextRunAt = %v, %t, %v; want %v", at, ok, err, runAt)
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import "time"
+
+// Progress is what a worker reports about a running job after its batches.
+type Progress struct {
+	// Batch is the number of batches committed; with RecordsInserted it is the checkpoint.
+	Batch           int
+	RecordsRead     int64
+	RecordsValid    int64
+	RecordsInvalid  int64
+	RecordsInserted int64
+	// RecordsResumed is the part of RecordsRead committed by earlier attempts and
+	// skipped by this one.
+	RecordsResumed int64
+	BytesRead      int64
+	// BytesTotal is zero when the blob size is unknown.
+	BytesTotal int64
+}
+
+// ProgressStats is derived from a job's progress counters when the job is read.
+type ProgressStats struct {
+	// Percent of the blob's bytes read, when its size is known.
+	Percent       *float64 `json:"percent,omitempty"`
+	RecordsPerSec float64  `json:"records_per_sec"`
+	BytesPerSec   float64  `json:"bytes_per_sec"`
+	// ETASeconds estimates the time left for a running job, when its size is known.
+	ETASeconds *float64 `json:"eta_seconds,omitempty"`
+}
+
+// progressStats computes the rates of the job's current or last attempt as of its last
+// progress update. It returns nil for jobs that have not reported progress in this attempt.
+// Records a resumed attempt skipped do not count towards its rates: skipping is far
+// faster than importing.
+func progressStats(j *Job, now time.Time) *ProgressStats {
+	if j.StartedAt == nil || j.CheckpointAt == nil || j.CheckpointAt.Before(*j.StartedAt) {
+		return nil
+	}
+	var ps ProgressStats
+	if elapsed := j.CheckpointAt.Sub(*j.StartedAt).Seconds(); elapsed > 0 {
+		ps.RecordsPerSec = float64(j.RecordsRead-j.RecordsResumed) / elapsed
+		ps.BytesPerSec = float64(j.BytesRead) / elapsed
+	}
+	if j.Status == StatusSucceeded {
+		pct := 100.0
+		ps.Percent = &pct
+		return &ps
+	}
+	if j.BytesTotal == nil || *j.BytesTotal <= 0 {
+		return &ps
+	}
+	pct := min(100, 100*float64(j.BytesRead)/float64(*j.BytesTotal))
+	ps.Percent = &pct
+	if j.Status == StatusRunning && ps.RecordsPerSec > 0 && j.BytesRead > 0 {
+		// the bytes left at the average record size, imported at this attempt's rate
+		bytesPerRecord := float64(j.BytesRead) / float64(j.RecordsRead)
+		eta := float64(*j.BytesTotal-j.BytesRead)/bytesPerRecord/ps.RecordsPerSec - now.Sub(*j.CheckpointAt).Seconds()
+		eta = max(eta, 0)
+		ps.ETASeconds = &eta
+	}
+	return &ps
+}
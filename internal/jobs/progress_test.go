@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProgressStats(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(sec int) *time.Time { tm := start.Add(time.Duration(sec) * time.Second); return &tm }
+	total := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name        string
+		job         Job
+		now         time.Time
+		want        *ProgressStats
+		wantPercent float64
+		wantETA     float64
+	}{
+		{"not started", Job{Status: StatusQueued}, start, nil, -1, -1},
+		{"checkpoint of an earlier attempt",
+			Job{Status: StatusRunning, StartedAt: at(10), CheckpointAt: at(5), RecordsRead: 100},
+			start, nil, -1, -1},
+		{"size unknown",
+			Job{Status: StatusRunning, StartedAt: at(0), CheckpointAt: at(10), RecordsRead: 1000, BytesRead: 50000},
+			*at(10), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 5000}, -1, -1},
+		{"running",
+			Job{Status: StatusRunning, StartedAt: at(0), CheckpointAt: at(10), RecordsRead: 1000, BytesRead: 50000, BytesTotal: total(200000)},
+			*at(10), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 5000}, 25, 30},
+		{"eta counts down from the checkpoint",
+			Job{Status: StatusRunning, StartedAt: at(0), CheckpointAt: at(10), RecordsRead: 1000, BytesRead: 50000, BytesTotal: total(200000)},
+			*at(14), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 5000}, 25, 26},
+		{"resumed attempt rates leave out skipped records",
+			// 3000 records were committed before; this attempt imported 1000 in 10s
+			Job{Status: StatusRunning, StartedAt: at(0), CheckpointAt: at(10), RecordsRead: 4000, RecordsResumed: 3000, BytesRead: 200000, BytesTotal: total(400000)},
+			*at(10), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 20000}, 50, 40},
+		{"resumed attempt still skipping",
+			Job{Status: StatusRunning, StartedAt: at(0), CheckpointAt: at(1), RecordsRead: 3000, RecordsResumed: 3000, BytesRead: 150000, BytesTotal: total(400000)},
+			*at(1), &ProgressStats{BytesPerSec: 150000}, 37.5, -1},
+		{"succeeded",
+			Job{Status: StatusSucceeded, StartedAt: at(0), CheckpointAt: at(20), RecordsRead: 2000, BytesRead: 100000},
+			*at(30), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 5000}, 100, -1},
+		{"failed keeps the share read",
+			Job{Status: StatusFailed, StartedAt: at(0), CheckpointAt: at(10), RecordsRead: 1000, BytesRead: 50000, BytesTotal: total(100000)},
+			*at(30), &ProgressStats{RecordsPerSec: 100, BytesPerSec: 5000}, 50, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := progressStats(&tt.job, tt.now)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("progressStats = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("progressStats = nil")
+			}
+			if !near(got.RecordsPerSec, tt.want.RecordsPerSec) || !near(got.BytesPerSec, tt.want.BytesPerSec) {
+				t.Errorf("rates = %v records/s, %v bytes/s; want %v, %v", got.RecordsPerSec, got.BytesPerSec, tt.want.RecordsPerSec, tt.want.BytesPerSec)
+			}
+			checkOptional(t, "percent", got.Percent, tt.wantPercent)
+			checkOptional(t, "eta_seconds", got.ETASeconds, tt.wantETA)
+		})
+	}
+}
+
+// checkOptional compares an optional stat with want, where a negative want means absent.
+func checkOptional(t *testing.T, name string, got *float64, want float64) {
+	t.Helper()
+	switch {
+	case want < 0 && got != nil:
+		t.Errorf("%s = %v, want absent", name, *got)
+	case want >= 0 && got == nil:
+		t.Errorf("%s absent, want %v", name, want)
+	case want >= 0 && !near(*got, want):
+		t.Errorf("%s = %v, want %v", name, *got, want)
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
@@ -2,8 +2,13 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/user/importer/internal/db"
 )
 
@@ -14,48 +19,284 @@ const (
 	StatusRunning   Status = "running"
 	StatusSucceeded Status = "succeeded"
 	StatusFailed    Status = "failed"
+	// StatusDead marks a job that exhausted its retries; it can be replayed.
+	StatusDead Status = "dead"
+	// StatusCancelled marks a job stopped on request.
+	StatusCancelled Status = "cancelled"
+	// StatusScheduled is reported, never stored, for a queued job whose run_at is
+	// still in the future.
+	StatusScheduled Status = "scheduled"
 )
 
+// CancelChannel is the Postgres NOTIFY channel carrying ids of running jobs to cancel.
+const CancelChannel = "import_job_cancel"
+
+// DefaultMaxAttempts is used when Repository.MaxAttempts is not set.
+const DefaultMaxAttempts = 3
+
+var (
+	// ErrNotFound is returned when a job id does not exist.
+	ErrNotFound = errors.New("job not found")
+	// ErrNotReplayable is returned when replaying a job that is not dead or failed.
+	ErrNotReplayable = errors.New("only dead or failed jobs can be replayed")
+	// ErrNotCancellable is returned when cancelling a job that already finished.
+	ErrNotCancellable = errors.New("only queued or running jobs can be cancelled")
+	// ErrIdempotencyConflict is returned when an idempotency key is reused for a different
+	// product type or blob.
+	ErrIdempotencyConflict = errors.New("idempotency key already used for a different import")
+	// ErrDuplicateContent matches DuplicateContentError.
+	ErrDuplicateContent = errors.New("blob content already imported")
+	// ErrLeaseLost is returned when recording the outcome of a job the worker no longer
+	// holds the lease on, e.g. because the reaper requeued it for another worker.
+	ErrLeaseLost = errors.New("job lease lost")
+)
+
+// DuplicateContentError refuses a job whose blob content was already imported
+// successfully for the same customer and product type.
+type DuplicateContentError struct {
+	// JobID is the job that imported the content.
+	JobID int64
+}
+
+func (e *DuplicateContentError) Error() string {
+	return fmt.Sprintf("%s by job %d", ErrDuplicateContent, e.JobID)
+}
+
+func (e *DuplicateContentError) Is(target error) bool { return target == ErrDuplicateContent }
+
 type Job struct {
-	ID          int64
-	CustomerID  string
-	ProductType string
-	BlobURI     string
-	Status      Status
+	ID              int64      `json:"id"`
+	CustomerID      string     `json:"customer_id"`
+	ProductType     string     `json:"product_type"`
+	BlobURI         string     `json:"blob_uri"`
+	Status          Status     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	ErrorText       string     `json:"error_text,omitempty"`
+	RecordsInserted int64      `json:"records_inserted"`
+	Attempts        int        `json:"attempts"`
+	MaxAttempts     int        `json:"max_attempts"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+	// WorkerID is the worker holding (or that last held) the job's lease.
+	WorkerID       string     `json:"worker_id,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	// CancelRequestedAt is set when cancellation of a running job was requested.
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty"`
+	Priority          int        `json:"priority"`
+	// RunAt is the requested start time of a scheduled job.
+	RunAt          *time.Time `json:"run_at,omitempty"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+	// ContentSHA256 is the hex SHA-256 of the imported blob, once known.
+	ContentSHA256 string `json:"content_sha256,omitempty"`
+	// CheckpointBatch and CheckpointRecords count the batches and source records
+	// committed so far; a retry resumes after them.
+	CheckpointBatch   int        `json:"checkpoint_batch"`
+	CheckpointRecords int64      `json:"checkpoint_records"`
+	CheckpointAt      *time.Time `json:"checkpoint_at,omitempty"`
+	// Progress counters of the current or last attempt, written with each checkpoint.
+	RecordsRead    int64 `json:"records_read"`
+	RecordsValid   int64 `json:"records_valid"`
+	RecordsInvalid int64 `json:"records_invalid"`
+	// RecordsResumed counts the records read that earlier attempts committed.
+	RecordsResumed int64 `json:"records_resumed"`
+	BytesRead      int64 `json:"bytes_read"`
+	// BytesTotal is the blob size, when the blob reader can tell.
+	BytesTotal *int64         `json:"bytes_total,omitempty"`
+	Progress   *ProgressStats `json:"progress,omitempty"`
+}
+
+// EnqueueOptions are optional settings of a new job.
+type EnqueueOptions struct {
+	// Priority orders dispatch: higher runs first, across all customers. Default 0.
+	Priority int
+	// RunAt delays the job until the given time; zero runs it as soon as possible.
+	RunAt time.Time
+	// IdempotencyKey makes retried enqueues safe: a repeat with the same key for the
+	// customer returns the original job instead of creating another.
+	IdempotencyKey string
+	// ContentSHA256, when set, refuses the job with a DuplicateContentError if a job of
+	// the same customer and product type already imported this content.
+	ContentSHA256 string
+}
+
+// LogEntry is one row of import_logs.
+type LogEntry struct {
+	ID        int64           `json:"id"`
+	JobID     int64           `json:"job_id"`
+	Level     string          `json:"level"`
+	Message   string          `json:"message"`
+	Context   json.RawMessage `json:"context,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Page size bounds for log queries.
+const (
+	DefaultLogLimit = 100
+	MaxLogLimit     = 1000
+)
+
+// statusExpr reports queued jobs that are waiting for their run_at as scheduled.
+const statusExpr = `CASE WHEN status='queued' AND run_at > now() THEN 'scheduled' ELSE status END`
+
+// jobColumns is the column list scanned by scanJob.
+const jobColumns = `id, customer_id, product_type, blob_uri, ` + statusExpr + `, created_at, updated_at, started_at, finished_at, COALESCE(error_text, ''), records_inserted, attempts, max_attempts, next_run_at, COALESCE(worker_id, ''), lease_expires_at, cancel_requested_at, priority, run_at, COALESCE(idempotency_key, ''), COALESCE(content_sha256, ''), checkpoint_batch, checkpoint_records, checkpoint_at, records_read, records_valid, records_invalid, records_resumed, bytes_read, bytes_total`
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var j Job
+	if err := row.Scan(&j.ID, &j.CustomerID, &j.ProductType, &j.BlobURI, &j.Status, &j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.FinishedAt, &j.ErrorText, &j.RecordsInserted, &j.Attempts, &j.MaxAttempts, &j.NextRunAt, &j.WorkerID, &j.LeaseExpiresAt, &j.CancelRequestedAt, &j.Priority, &j.RunAt, &j.IdempotencyKey, &j.ContentSHA256, &j.CheckpointBatch, &j.CheckpointRecords, &j.CheckpointAt, &j.RecordsRead, &j.RecordsValid, &j.RecordsInvalid, &j.RecordsResumed, &j.BytesRead, &j.BytesTotal); err != nil {
+		return nil, err
+	}
+	j.Progress = progressStats(&j, time.Now())
+	return &j, nil
 }
 
 type Repository struct {
 	DB *db.AppDB
+	// MaxAttempts is stored on each enqueued job; DefaultMaxAttempts when zero.
+	MaxAttempts int
+	// MaxRunningPerCustomer caps a customer's concurrently running jobs across all
+	// worker processes; zero means no cap.
+	MaxRunningPerCustomer int
 }
 
 func NewRepository(adb *db.AppDB) *Repository { return &Repository{DB: adb} }
 
-func (r *Repository) Enqueue(ctx context.Context, customerID, productType, blobURI string) (int64, error) {
-	row := r.DB.Pool.QueryRow(ctx, `INSERT INTO import_jobs(customer_id, product_type, blob_uri, status) VALUES($1,$2,$3,'queued') RETURNING id`, customerID, productType, blobURI)
-	var id int64
-	if err := row.Scan(&id); err != nil {
+func (r *Repository) Enqueue(ctx context.Context, customerID, productType, blobURI string, opts EnqueueOptions) (int64, error) {
+	if opts.IdempotencyKey != "" {
+		if id, ok, err := r.IdempotentJob(ctx, customerID, productType, blobURI, opts.IdempotencyKey); err != nil || ok {
+			return id, err
+		}
+	}
+	id, err := r.insertJob(ctx, r.DB.Pool, customerID, productType, blobURI, opts)
+	if err == pgx.ErrNoRows {
+		// a concurrent enqueue with the same key won the insert
+		id, _, err = r.IdempotentJob(ctx, customerID, productType, blobURI, opts.IdempotencyKey)
+		return id, err
+	}
+	if err != nil {
 		return 0, err
 	}
-	r.Log(ctx, id, "info", "job enqueued", []byte(`{"customer_id":"`+customerID+`","product_type":"`+productType+`","blob_uri":"`+blobURI+`"}`))
-	
+	r.enqueued(ctx, id, customerID, productType, blobURI)
 	return id, nil
 }
 
-func (r *Repository) FetchAndStart(ctx context.Context) (*Job, error) {
+// querier runs statements on the pool or in a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertJob inserts a queued job through q. It returns pgx.ErrNoRows when a job with
+// the same idempotency key exists.
+func (r *Repository) insertJob(ctx context.Context, q querier, customerID, productType, blobURI string, opts EnqueueOptions) (int64, error) {
+	if opts.ContentSHA256 != "" {
+		if dup, ok, err := importedContent(ctx, q, customerID, productType, opts.ContentSHA256, 0); err != nil {
+			return 0, err
+		} else if ok {
+			return 0, &DuplicateContentError{JobID: dup}
+		}
+	}
+	maxAttempts := r.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	var runAt *time.Time
+	if !opts.RunAt.IsZero() {
+		runAt = &opts.RunAt
+	}
+	var id int64
+	err := q.QueryRow(ctx, `
+INSERT INTO import_jobs(customer_id, product_type, blob_uri, status, max_attempts, priority, run_at, next_run_at, idempotency_key, content_sha256)
+VALUES($1,$2,$3,'queued',$4,$5,$6,COALESCE($6, now()),NULLIF($7, ''),NULLIF($8, ''))
+ON CONFLICT (customer_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+RETURNING id`, customerID, productType, blobURI, maxAttempts, opts.Priority, runAt, opts.IdempotencyKey, opts.ContentSHA256).Scan(&id)
+	return id, err
+}
+
+// enqueued logs a committed new job and wakes idle workers.
+func (r *Repository) enqueued(ctx context.Context, id int64, customerID, productType, blobURI string) {
+	r.Log(ctx, id, "info", "job enqueued", []byte(`{"customer_id":"`+customerID+`","product_type":"`+productType+`","blob_uri":"`+blobURI+`"}`))
+	// Also for jobs due later: idle workers then shorten their sleep to the new NextRunAt.
+	_ = r.notify(ctx, ReadyChannel, fmt.Sprint(id))
+}
+
+// IdempotentJob looks up the job a customer enqueued with key. It returns
+// ErrIdempotencyConflict when that job imports a different product type or blob.
+func (r *Repository) IdempotentJob(ctx context.Context, customerID, productType, blobURI, key string) (int64, bool, error) {
+	var id int64
+	var prod, blob string
+	err := r.DB.Pool.QueryRow(ctx, `SELECT id, product_type, blob_uri FROM import_jobs WHERE customer_id=$1 AND idempotency_key=$2`,
+		customerID, key).Scan(&id, &prod, &blob)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if prod != productType || blob != blobURI {
+		return 0, false, fmt.Errorf("%w: job %d", ErrIdempotencyConflict, id)
+	}
+	return id, true, nil
+}
+
+// ImportedContent returns a job other than excludeID that successfully imported
+// content with the given SHA-256 for the customer and product type.
+func (r *Repository) ImportedContent(ctx context.Context, customerID, productType, sha256 string, excludeID int64) (int64, bool, error) {
+	return importedContent(ctx, r.DB.Pool, customerID, productType, sha256, excludeID)
+}
+
+// importedContent is ImportedContent through q, so a transaction sees its own snapshot.
+func importedContent(ctx context.Context, q querier, customerID, productType, sha256 string, excludeID int64) (int64, bool, error) {
+	var id int64
+	err := q.QueryRow(ctx, `
+SELECT id FROM import_jobs
+WHERE customer_id=$1 AND product_type=$2 AND content_sha256=$3 AND status='succeeded' AND id <> $4
+ORDER BY id LIMIT 1`, customerID, productType, sha256, excludeID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// FetchAndStart claims the next eligible queued job for workerID, marking it running
+// with a lease that expires after lease unless renewed by Heartbeat.
+//
+// Jobs are taken by priority, then fair share: among equal priorities the customer with
+// the fewest running jobs goes first, so one customer's backlog cannot starve the others.
+// Customers at MaxRunningPerCustomer are skipped. With a cap, dequeues are serialized by
+// an advisory lock so concurrent workers in any process cannot overshoot it.
+func (r *Repository) FetchAndStart(ctx context.Context, workerID string, lease time.Duration) (*Job, error) {
 	tx, err := r.DB.Pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
-	var j Job
+	if r.MaxRunningPerCustomer > 0 {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('import_jobs_dequeue'))`); err != nil {
+			return nil, err
+		}
+	}
 	// Select for update skip locked to prevent contention
 	row := tx.QueryRow(ctx, `
-UPDATE import_jobs SET status='running', started_at = now()
+UPDATE import_jobs SET status='running', started_at = now(), finished_at = NULL, attempts = attempts + 1,
+  worker_id = $1, lease_expires_at = now() + make_interval(secs => $2)
 WHERE id = (
-  SELECT id FROM import_jobs WHERE status='queued' ORDER BY created_at ASC FOR UPDATE SKIP LOCKED LIMIT 1
-) RETURNING id, customer_id, product_type, blob_uri, status
-`)
-	if err := row.Scan(&j.ID, &j.CustomerID, &j.ProductType, &j.BlobURI, &j.Status); err != nil {
+  SELECT j.id FROM import_jobs j
+  LEFT JOIN (
+    SELECT customer_id, count(*) AS n FROM import_jobs WHERE status='running' GROUP BY customer_id
+  ) running ON running.customer_id = j.customer_id
+  WHERE j.status='queued' AND j.next_run_at <= now()
+    AND ($3 = 0 OR COALESCE(running.n, 0) < $3)
+  ORDER BY j.priority DESC, COALESCE(running.n, 0) ASC, j.created_at ASC
+  FOR UPDATE OF j SKIP LOCKED LIMIT 1
+) RETURNING `+jobColumns, workerID, lease.Seconds(), r.MaxRunningPerCustomer)
+	j, err := scanJob(row)
+	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
@@ -64,25 +305,303 @@ WHERE id = (
 	if err := tx.Commit(ctx); err != nil {
 		return nil, err
 	}
-	r.Log(ctx, j.ID, "info", "job started running", nil)
-	return &j, nil
+	r.Log(ctx, j.ID, "info", "job started running", []byte(fmt.Sprintf(`{"worker_id":%q,"attempt":%d}`, workerID, j.Attempts)))
+	return j, nil
 }
 
-func (r *Repository) Complete(ctx context.Context, jobID int64) error {
-	_, err := r.DB.Pool.Exec(ctx, `UPDATE import_jobs SET status='succeeded', finished_at=now() WHERE id=$1`, jobID)
-	r.Log(ctx, jobID, "info", "job completed successfully", nil)
+// Heartbeat extends the lease workerID holds on a running job. It returns false when
+// the worker no longer holds the lease, e.g. because the reaper requeued the job.
+func (r *Repository) Heartbeat(ctx context.Context, jobID int64, workerID string, lease time.Duration) (bool, error) {
+	tag, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET lease_expires_at = now() + make_interval(secs => $3)
+WHERE id=$1 AND worker_id=$2 AND status='running'`, jobID, workerID, lease.Seconds())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReapExpired recovers running jobs whose lease has expired, such as jobs of a crashed
+// worker. Each is requeued, or moved to StatusDead when it has used all of its attempts,
+// and the worker that lost it is logged. It returns the number of jobs reaped. Jobs
+// without a lease, left running by workers from before leases, are never reaped.
+func (r *Repository) ReapExpired(ctx context.Context) (int, error) {
+	rows, err := r.DB.Pool.Query(ctx, `
+UPDATE import_jobs SET
+  status = CASE WHEN cancel_requested_at IS NOT NULL THEN 'cancelled' WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+  finished_at = CASE WHEN cancel_requested_at IS NOT NULL OR attempts >= max_attempts THEN now() END,
+  next_run_at = now(),
+  error_text = 'lease expired on worker ' || COALESCE(worker_id, 'unknown'),
+  lease_expires_at = NULL
+WHERE id IN (
+  SELECT id FROM import_jobs WHERE status='running' AND lease_expires_at < now() FOR UPDATE SKIP LOCKED
+) RETURNING id, COALESCE(worker_id, ''), status, attempts, max_attempts`)
+	if err != nil {
+		return 0, err
+	}
+	type reaped struct {
+		id                    int64
+		worker                string
+		status                Status
+		attempts, maxAttempts int
+	}
+	var out []reaped
+	for rows.Next() {
+		var x reaped
+		if err := rows.Scan(&x.id, &x.worker, &x.status, &x.attempts, &x.maxAttempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		out = append(out, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, x := range out {
+		logCtx, _ := json.Marshal(map[string]any{"worker_id": x.worker, "attempts": x.attempts, "max_attempts": x.maxAttempts, "status": x.status})
+		r.Log(ctx, x.id, "warn", "job lease expired, worker lost the job", logCtx)
+		if x.status == StatusQueued {
+			_ = r.notify(ctx, ReadyChannel, fmt.Sprint(x.id))
+		}
+	}
+	return len(out), nil
+}
+
+// Get returns a job by id, or ErrNotFound.
+func (r *Repository) Get(ctx context.Context, jobID int64) (*Job, error) {
+	j, err := scanJob(r.DB.Pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM import_jobs WHERE id=$1`, jobID))
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return j, err
+}
+
+// Logs returns up to limit log entries of a job with id greater than afterID, oldest first.
+// Pass the last returned id as afterID to fetch the next page.
+func (r *Repository) Logs(ctx context.Context, jobID, afterID int64, limit int) ([]LogEntry, error) {
+	if limit <= 0 {
+		limit = DefaultLogLimit
+	}
+	if limit > MaxLogLimit {
+		limit = MaxLogLimit
+	}
+	rows, err := r.DB.Pool.Query(ctx, `
+SELECT id, job_id, level, message, context, created_at FROM import_logs
+WHERE job_id=$1 AND id > $2 ORDER BY id ASC LIMIT $3`, jobID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	logs := []LogEntry{}
+	for rows.Next() {
+		var l LogEntry
+		if err := rows.Scan(&l.ID, &l.JobID, &l.Level, &l.Message, &l.Context, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+// SetRecordsInserted records how many records the job has inserted into the customer DB.
+func (r *Repository) SetRecordsInserted(ctx context.Context, jobID, n int64) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE import_jobs SET records_inserted=$2 WHERE id=$1`, jobID, n)
 	return err
 }
 
-func (r *Repository) Fail(ctx context.Context, jobID int64, errText string) error {
-	_, err := r.DB.Pool.Exec(ctx, `UPDATE import_jobs SET status='failed', finished_at=now(), error_text=$2 WHERE id=$1`, jobID, errText)
-	r.Log(ctx, jobID, "error", "job failed", []byte(`{"error":"`+errText+`"}`))
+// Checkpoint records that the job has committed batch batches holding records source
+// records in total.
+func (r *Repository) Checkpoint(ctx context.Context, jobID int64, batch int, records int64) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET checkpoint_batch=$2, checkpoint_records=$3, checkpoint_at=now(), records_inserted=$3
+WHERE id=$1`, jobID, batch, records)
 	return err
 }
 
-func (r *Repository) Log(ctx context.Context, jobID int64, level, message string, contextJSON []byte) error {
-	_, err := r.DB.Pool.Exec(ctx, `INSERT INTO import_logs(job_id, level, message, context) VALUES ($1,$2,$3,$4)`, jobID, level, message, contextJSON)
+// UpdateProgress writes a running job's progress counters and its checkpoint, and
+// signals the job's watchers.
+func (r *Repository) UpdateProgress(ctx context.Context, jobID int64, p Progress) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+WITH u AS (
+  UPDATE import_jobs SET checkpoint_batch=$2, checkpoint_records=$3, checkpoint_at=now(), records_inserted=$3,
+    records_read=$4, records_valid=$5, records_invalid=$6, records_resumed=$7, bytes_read=$8, bytes_total=NULLIF($9::bigint, 0)
+  WHERE id=$1 RETURNING id
+)
+SELECT pg_notify($10, id::text) FROM u`, jobID, p.Batch, p.RecordsInserted, p.RecordsRead, p.RecordsValid, p.RecordsInvalid, p.RecordsResumed, p.BytesRead, p.BytesTotal, EventsChannel)
+	return err
+}
+
+// SetContentSHA256 records the hex SHA-256 of the job's blob.
+func (r *Repository) SetContentSHA256(ctx context.Context, jobID int64, sum string) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE import_jobs SET content_sha256=$2 WHERE id=$1`, jobID, sum)
 	return err
 }
 
+// Complete records that workerID finished the job successfully. It returns
+// ErrLeaseLost, recording nothing, when the worker no longer holds the job's lease.
+func (r *Repository) Complete(ctx context.Context, jobID int64, workerID string) error {
+	tag, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET status='succeeded', finished_at=now(), lease_expires_at=NULL
+WHERE id=$1 AND worker_id=$2 AND status='running'`, jobID, workerID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d, worker %s", ErrLeaseLost, jobID, workerID)
+	}
+	r.Log(ctx, jobID, "info", "job completed successfully", nil)
+	return nil
+}
+
+// Fail records a permanent failure of the job workerID ran. It returns ErrLeaseLost,
+// recording nothing, when the worker no longer holds the job's lease.
+func (r *Repository) Fail(ctx context.Context, jobID int64, workerID, errText string) error {
+	tag, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET status='failed', finished_at=now(), lease_expires_at=NULL, error_text=$3
+WHERE id=$1 AND worker_id=$2 AND status='running'`, jobID, workerID, errText)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d, worker %s", ErrLeaseLost, jobID, workerID)
+	}
+	r.Log(ctx, jobID, "error", "job failed", []byte(`{"error":"`+errText+`"}`))
+	return nil
+}
 
+// Retry records a retryable failure of a job workerID ran. The job is requeued to run
+// after delay, or moved to StatusDead when it has used all of its attempts. It returns
+// the new status, or ErrLeaseLost when the worker no longer holds the job's lease.
+func (r *Repository) Retry(ctx context.Context, jobID int64, workerID, errText string, delay time.Duration) (Status, error) {
+	var st Status
+	var attempts, maxAttempts int
+	err := r.DB.Pool.QueryRow(ctx, `
+UPDATE import_jobs SET
+  status = CASE WHEN cancel_requested_at IS NOT NULL THEN 'cancelled' WHEN attempts >= max_attempts THEN 'dead' ELSE 'queued' END,
+  finished_at = CASE WHEN cancel_requested_at IS NOT NULL OR attempts >= max_attempts THEN now() END,
+  next_run_at = now() + make_interval(secs => $4),
+  lease_expires_at = NULL,
+  error_text = $3
+WHERE id=$1 AND worker_id=$2 AND status='running' RETURNING status, attempts, max_attempts`, jobID, workerID, errText, delay.Seconds()).Scan(&st, &attempts, &maxAttempts)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("%w: job %d, worker %s", ErrLeaseLost, jobID, workerID)
+	}
+	if err != nil {
+		return "", err
+	}
+	logCtx, _ := json.Marshal(map[string]any{"error": errText, "attempts": attempts, "max_attempts": maxAttempts, "retry_in_sec": delay.Seconds()})
+	if st == StatusQueued {
+		// idle workers reschedule their wake-up for the retry
+		_ = r.notify(ctx, ReadyChannel, fmt.Sprint(jobID))
+	}
+	switch st {
+	case StatusCancelled:
+		r.Log(ctx, jobID, "info", "job failed after cancellation was requested, cancelled", logCtx)
+	case StatusDead:
+		r.Log(ctx, jobID, "error", "job retries exhausted, moved to dead", logCtx)
+	default:
+		r.Log(ctx, jobID, "warn", "job failed, requeued for retry", logCtx)
+	}
+	return st, nil
+}
+
+// Cancel stops a job. A queued job is cancelled at once; for a running job cancellation
+// is requested and its worker stops it, deleting the rows it inserted when rollback is set.
+// It returns the job's status after the call: StatusCancelled or StatusRunning.
+func (r *Repository) Cancel(ctx context.Context, jobID int64, rollback bool) (Status, error) {
+	tx, err := r.DB.Pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	var st Status
+	err = tx.QueryRow(ctx, `
+UPDATE import_jobs SET
+  status = CASE WHEN status='queued' THEN 'cancelled' ELSE status END,
+  finished_at = CASE WHEN status='queued' THEN now() ELSE finished_at END,
+  cancel_requested_at = COALESCE(cancel_requested_at, now()),
+  cancel_rollback = $2
+WHERE id=$1 AND status IN ('queued', 'running') RETURNING status`, jobID, rollback).Scan(&st)
+	if err == pgx.ErrNoRows {
+		j, gerr := r.Get(ctx, jobID)
+		if gerr != nil {
+			return "", gerr
+		}
+		return "", fmt.Errorf("%w: job %d is %s", ErrNotCancellable, jobID, j.Status)
+	}
+	if err != nil {
+		return "", err
+	}
+	if st == StatusRunning {
+		// Delivered on commit; workers also poll the flag between batches.
+		if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, CancelChannel, fmt.Sprint(jobID)); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	if st == StatusCancelled {
+		r.Log(ctx, jobID, "info", "job cancelled while queued", nil)
+	} else {
+		r.Log(ctx, jobID, "info", "job cancellation requested", []byte(fmt.Sprintf(`{"rollback":%t}`, rollback)))
+	}
+	return st, nil
+}
+
+// CancelRequested reports whether cancellation of the job was requested, and whether
+// its inserted rows should be rolled back.
+func (r *Repository) CancelRequested(ctx context.Context, jobID int64) (requested, rollback bool, err error) {
+	err = r.DB.Pool.QueryRow(ctx, `SELECT cancel_requested_at IS NOT NULL, cancel_rollback FROM import_jobs WHERE id=$1`, jobID).Scan(&requested, &rollback)
+	if err == pgx.ErrNoRows {
+		return false, false, ErrNotFound
+	}
+	return requested, rollback, err
+}
+
+// MarkCancelled records that workerID stopped a running job on request. It returns
+// ErrLeaseLost, recording nothing, when the worker no longer holds the job's lease.
+func (r *Repository) MarkCancelled(ctx context.Context, jobID int64, workerID string) error {
+	tag, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET status='cancelled', finished_at=now(), lease_expires_at=NULL
+WHERE id=$1 AND worker_id=$2 AND status='running'`, jobID, workerID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: job %d, worker %s", ErrLeaseLost, jobID, workerID)
+	}
+	r.Log(ctx, jobID, "info", "job cancelled", nil)
+	return nil
+}
+
+// Replay requeues a dead or failed job to run now with a fresh set of attempts.
+func (r *Repository) Replay(ctx context.Context, jobID int64) error {
+	tag, err := r.DB.Pool.Exec(ctx, `
+UPDATE import_jobs SET status='queued', attempts=0, next_run_at=now(), started_at=NULL, finished_at=NULL, error_text=NULL,
+  cancel_requested_at=NULL, cancel_rollback=false
+WHERE id=$1 AND status IN ('dead', 'failed')`, jobID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		j, err := r.Get(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: job %d is %s", ErrNotReplayable, jobID, j.Status)
+	}
+	r.Log(ctx, jobID, "info", "job replayed", nil)
+	_ = r.notify(ctx, ReadyChannel, fmt.Sprint(jobID))
+	return nil
+}
+
+// Log appends a log entry to the job and signals its watchers. Every status
+// transition is logged, so watchers see those too.
+func (r *Repository) Log(ctx context.Context, jobID int64, level, message string, contextJSON []byte) error {
+	_, err := r.DB.Pool.Exec(ctx, `
+WITH l AS (INSERT INTO import_logs(job_id, level, message, context) VALUES ($1,$2,$3,$4) RETURNING job_id)
+SELECT pg_notify($5, job_id::text) FROM l`, jobID, level, message, contextJSON, EventsChannel)
+	return err
+}
@@ -0,0 +1,203 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/importer/internal/db/dbtest"
+)
+
+func newTestRepo(t *testing.T) *Repository {
+	return NewRepository(dbtest.AppDB(t))
+}
+
+func enqueue(t *testing.T, r *Repository, customerID string, opts EnqueueOptions) int64 {
+	t.Helper()
+	id, err := r.Enqueue(context.Background(), customerID, "users", "/data/users.csv", opts)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return id
+}
+
+func fetch(t *testing.T, r *Repository, workerID string, lease time.Duration) *Job {
+	t.Helper()
+	j, err := r.FetchAndStart(context.Background(), workerID, lease)
+	if err != nil {
+		t.Fatalf("FetchAndStart: %v", err)
+	}
+	return j
+}
+
+func status(t *testing.T, r *Repository, id int64) Status {
+	t.Helper()
+	j, err := r.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	return j.Status
+}
+
+func TestFetchAndStartClaimsWithLease(t *testing.T) {
+	r := newTestRepo(t)
+	if j := fetch(t, r, "w1", time.Minute); j != nil {
+		t.Fatalf("FetchAndStart on an empty queue = job %d, want nil", j.ID)
+	}
+	id := enqueue(t, r, "c1", EnqueueOptions{})
+	j := fetch(t, r, "w1", time.Minute)
+	if j == nil || j.ID != id {
+		t.Fatalf("FetchAndStart = %v, want job %d", j, id)
+	}
+	if j.Status != StatusRunning || j.WorkerID != "w1" || j.Attempts != 1 || j.LeaseExpiresAt == nil {
+		t.Fatalf("claimed job = status %s, worker %q, attempts %d, lease %v", j.Status, j.WorkerID, j.Attempts, j.LeaseExpiresAt)
+	}
+	if j := fetch(t, r, "w2", time.Minute); j != nil {
+		t.Fatalf("second FetchAndStart = job %d, want nil while the first is running", j.ID)
+	}
+}
+
+func TestFetchAndStartOrder(t *testing.T) {
+	r := newTestRepo(t)
+	r.MaxRunningPerCustomer = 1
+	a1 := enqueue(t, r, "a", EnqueueOptions{})
+	enqueue(t, r, "a", EnqueueOptions{})
+	b1 := enqueue(t, r, "b", EnqueueOptions{})
+	urgent := enqueue(t, r, "b", EnqueueOptions{Priority: 10})
+	enqueue(t, r, "c", EnqueueOptions{RunAt: time.Now().Add(time.Hour)})
+
+	// priority first; then customer b is at its cap, so a goes; then both are capped
+	for _, want := range []int64{urgent, a1} {
+		if j := fetch(t, r, "w", time.Minute); j == nil || j.ID != want {
+			t.Fatalf("FetchAndStart = %v, want job %d", j, want)
+		}
+	}
+	if j := fetch(t, r, "w", time.Minute); j != nil {
+		t.Fatalf("FetchAndStart = job %d, want nil with every customer at its cap or scheduled later", j.ID)
+	}
+	if err := r.Complete(context.Background(), urgent, "w"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if j := fetch(t, r, "w", time.Minute); j == nil || j.ID != b1 {
+		t.Fatalf("FetchAndStart = %v, want job %d once b is under its cap", j, b1)
+	}
+}
+
+func TestOutcomeRequiresLease(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		record func(r *Repository, id int64, workerID string) error
+		want   Status
+	}{
+		{"complete", func(r *Repository, id int64, w string) error { return r.Complete(ctx, id, w) }, StatusSucceeded},
+		{"fail", func(r *Repository, id int64, w string) error { return r.Fail(ctx, id, w, "bad row") }, StatusFailed},
+		{"retry", func(r *Repository, id int64, w string) error {
+			_, err := r.Retry(ctx, id, w, "timeout", time.Hour)
+			return err
+		}, StatusQueued},
+		{"cancel", func(r *Repository, id int64, w string) error { return r.MarkCancelled(ctx, id, w) }, StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			id := enqueue(t, r, "c1", EnqueueOptions{})
+			fetch(t, r, "owner", time.Minute)
+			if err := tt.record(r, id, "intruder"); !errors.Is(err, ErrLeaseLost) {
+				t.Fatalf("other worker: err = %v, want ErrLeaseLost", err)
+			}
+			if got := status(t, r, id); got != StatusRunning {
+				t.Fatalf("status after other worker = %s, want running", got)
+			}
+			if err := tt.record(r, id, "owner"); err != nil {
+				t.Fatalf("owner: %v", err)
+			}
+			if got := status(t, r, id); got != tt.want {
+				t.Fatalf("status = %s, want %s", got, tt.want)
+			}
+			if err := tt.record(r, id, "owner"); !errors.Is(err, ErrLeaseLost) {
+				t.Fatalf("second outcome: err = %v, want ErrLeaseLost", err)
+			}
+		})
+	}
+}
+
+func TestReapExpiredFencesOldWorker(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	id := enqueue(t, r, "c1", EnqueueOptions{})
+	fetch(t, r, "crashed", time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	n, err := r.ReapExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("ReapExpired = %d, %v; want 1", n, err)
+	}
+	if got := status(t, r, id); got != StatusQueued {
+		t.Fatalf("status after reaping = %s, want queued", got)
+	}
+	if held, err := r.Heartbeat(ctx, id, "crashed", time.Minute); err != nil || held {
+		t.Fatalf("Heartbeat of reaped worker = %t, %v; want false", held, err)
+	}
+
+	j := fetch(t, r, "second", time.Minute)
+	if j == nil || j.ID != id || j.Attempts != 2 {
+		t.Fatalf("reclaimed job = %+v, want job %d on attempt 2", j, id)
+	}
+	if err := r.Complete(ctx, id, "crashed"); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Complete by reaped worker: err = %v, want ErrLeaseLost", err)
+	}
+	if _, err := r.Retry(ctx, id, "crashed", "late failure", 0); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("Retry by reaped worker: err = %v, want ErrLeaseLost", err)
+	}
+	if err := r.Complete(ctx, id, "second"); err != nil {
+		t.Fatalf("Complete by new owner: %v", err)
+	}
+	if got := status(t, r, id); got != StatusSucceeded {
+		t.Fatalf("status = %s, want succeeded", got)
+	}
+}
+
+func TestReapExpiredLastAttemptIsDead(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	r.MaxAttempts = 1
+	id := enqueue(t, r, "c1", EnqueueOptions{})
+	fetch(t, r, "crashed", time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if n, err := r.ReapExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("ReapExpired = %d, %v; want 1", n, err)
+	}
+	if got := status(t, r, id); got != StatusDead {
+		t.Fatalf("status = %s, want dead", got)
+	}
+}
+
+func TestReapExpiredKeepsLiveLeases(t *testing.T) {
+	r := newTestRepo(t)
+	id := enqueue(t, r, "c1", EnqueueOptions{})
+	fetch(t, r, "alive", time.Minute)
+	if n, err := r.ReapExpired(context.Background()); err != nil || n != 0 {
+		t.Fatalf("ReapExpired = %d, %v; want 0", n, err)
+	}
+	if got := status(t, r, id); got != StatusRunning {
+		t.Fatalf("status = %s, want running", got)
+	}
+}
+
+func TestReapExpiredSkipsJobsWithoutLease(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepo(t)
+	id := enqueue(t, r, "c1", EnqueueOptions{})
+	// running on a worker from before leases
+	if _, err := r.DB.Pool.Exec(ctx, `UPDATE import_jobs SET status='running', started_at=now() - interval '1 day' WHERE id=$1`, id); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.ReapExpired(ctx); err != nil || n != 0 {
+		t.Fatalf("ReapExpired = %d, %v; want 0", n, err)
+	}
+	if got := status(t, r, id); got != StatusRunning {
+		t.Fatalf("status = %s, want running", got)
+	}
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrUploadNotFound is returned when an upload id does not exist.
+var ErrUploadNotFound = errors.New("upload not found")
+
+// Upload records a file received through an upload endpoint and the job importing it.
+type Upload struct {
+	ID          int64     `json:"id"`
+	CustomerID  string    `json:"customer_id"`
+	ProductType string    `json:"product_type"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type,omitempty"`
+	BlobURI     string    `json:"blob_uri"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SHA256      string    `json:"sha256"`
+	JobID       *int64    `json:"job_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EnqueueUpload records a received upload and enqueues the job importing it in one
+// transaction, so an upload is never recorded without its job. It sets the upload's
+// ID, CreatedAt and JobID.
+func (r *Repository) EnqueueUpload(ctx context.Context, u *Upload, opts EnqueueOptions) error {
+	tx, err := r.DB.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	err = tx.QueryRow(ctx, `
+INSERT INTO import_uploads(customer_id, product_type, filename, content_type, blob_uri, size_bytes, sha256)
+VALUES($1,$2,$3,NULLIF($4, ''),$5,$6,$7)
+RETURNING id, created_at`, u.CustomerID, u.ProductType, u.Filename, u.ContentType, u.BlobURI, u.SizeBytes, u.SHA256).Scan(&u.ID, &u.CreatedAt)
+	if err != nil {
+		return err
+	}
+	jobID, err := r.insertJob(ctx, tx, u.CustomerID, u.ProductType, u.BlobURI, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE import_uploads SET job_id=$2 WHERE id=$1`, u.ID, jobID); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	u.JobID = &jobID
+	r.enqueued(ctx, jobID, u.CustomerID, u.ProductType, u.BlobURI)
+	return nil
+}
+
+func (r *Repository) GetUpload(ctx context.Context, id int64) (*Upload, error) {
+	var u Upload
+	var contentType *string
+	err := r.DB.Pool.QueryRow(ctx, `
+SELECT id, customer_id, product_type, filename, content_type, blob_uri, size_bytes, sha256, job_id, created_at
+FROM import_uploads WHERE id=$1`, id).Scan(&u.ID, &u.CustomerID, &u.ProductType, &u.Filename, &contentType, &u.BlobURI, &u.SizeBytes, &u.SHA256, &u.JobID, &u.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if contentType != nil {
+		u.ContentType = *contentType
+	}
+	return &u, nil
+}
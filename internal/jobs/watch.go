@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventsChannel is the Postgres NOTIFY channel carrying the id of a job whose status,
+// progress or logs changed.
+const EventsChannel = "import_job_events"
+
+// watchFallback is how often a watcher re-reads its job without a notification, which
+// covers notifications missed while the listener reconnects and scheduled jobs
+// becoming due.
+const watchFallback = 30 * time.Second
+
+// Event types passed to Watch callbacks.
+const (
+	// EventStatus carries the job when its status changed, and first when watching starts.
+	EventStatus = "status"
+	// EventProgress carries the job when its progress counters changed.
+	EventProgress = "progress"
+	// EventLog carries a new log entry.
+	EventLog = "log"
+)
+
+// Event is a change of a watched job.
+type Event struct {
+	Type string
+	Job  *Job
+	Log  *LogEntry
+}
+
+// Terminal reports whether a job in status st will not change again without a replay.
+func Terminal(st Status) bool {
+	switch st {
+	case StatusSucceeded, StatusFailed, StatusDead, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// Hub delivers job change notifications to the watchers of each job, so any number of
+// watchers share one LISTEN connection.
+type Hub struct {
+	Repo *Repository
+
+	mu   sync.Mutex
+	subs map[int64]map[chan struct{}]struct{}
+}
+
+func NewHub(r *Repository) *Hub {
+	return &Hub{Repo: r, subs: map[int64]map[chan struct{}]struct{}{}}
+}
+
+// Run listens for job events until ctx is done. After a reconnect every watcher is
+// woken, since notifications may have been missed.
+func (h *Hub) Run(ctx context.Context) {
+	h.Repo.Listen(ctx, []string{EventsChannel}, h.wakeAll, func(_, payload string) {
+		if id, err := strconv.ParseInt(payload, 10, 64); err == nil {
+			h.wake(id)
+		}
+	})
+}
+
+func (h *Hub) subscribe(jobID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[jobID] == nil {
+		h.subs[jobID] = map[chan struct{}]struct{}{}
+	}
+	h.subs[jobID][ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[jobID], ch)
+		if len(h.subs[jobID]) == 0 {
+			delete(h.subs, jobID)
+		}
+	}
+}
+
+func (h *Hub) wake(jobID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[jobID] {
+		signal(ch)
+	}
+}
+
+func (h *Hub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, chans := range h.subs {
+		for ch := range chans {
+			signal(ch)
+		}
+	}
+}
+
+// signal wakes a watcher without blocking; one pending wake-up is enough.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Watch calls fn with the job's current state, then with each status transition,
+// progress update and log entry after afterLogID, until the job reaches a terminal
+// status, ctx is done or fn returns an error. It returns ErrNotFound for unknown jobs.
+func (h *Hub) Watch(ctx context.Context, jobID, afterLogID int64, fn func(Event) error) error {
+	wake, unsubscribe := h.subscribe(jobID)
+	defer unsubscribe()
+
+	j, err := h.Repo.Get(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if err := fn(Event{Type: EventStatus, Job: j}); err != nil {
+		return err
+	}
+	lastLog, err := h.sendLogs(ctx, jobID, afterLogID, fn)
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTimer(watchFallback)
+	defer t.Stop()
+	for !Terminal(j.Status) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-t.C:
+		}
+		t.Reset(watchFallback)
+
+		cur, err := h.Repo.Get(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		// logs first: a transition's log entry explains the status that follows it
+		if lastLog, err = h.sendLogs(ctx, jobID, lastLog, fn); err != nil {
+			return err
+		}
+		switch {
+		case cur.Status != j.Status:
+			err = fn(Event{Type: EventStatus, Job: cur})
+		case progressChanged(j, cur):
+			err = fn(Event{Type: EventProgress, Job: cur})
+		}
+		if err != nil {
+			return err
+		}
+		j = cur
+	}
+	return nil
+}
+
+// sendLogs passes the job's log entries after afterID to fn and returns the last id sent.
+func (h *Hub) sendLogs(ctx context.Context, jobID, afterID int64, fn func(Event) error) (int64, error) {
+	for {
+		logs, err := h.Repo.Logs(ctx, jobID, afterID, MaxLogLimit)
+		if err != nil {
+			return afterID, err
+		}
+		for i := range logs {
+			if err := fn(Event{Type: EventLog, Log: &logs[i]}); err != nil {
+				return afterID, err
+			}
+			afterID = logs[i].ID
+		}
+		if len(logs) < MaxLogLimit {
+			return afterID, nil
+		}
+	}
+}
+
+func progressChanged(a, b *Job) bool {
+	return a.RecordsRead != b.RecordsRead || a.RecordsInserted != b.RecordsInserted ||
+		a.BytesRead != b.BytesRead || a.CheckpointBatch != b.CheckpointBatch
+}
@@ -15,9 +15,27 @@ import (
 // Record is a generic parsed map that will be validated per product schema.
 type Record map[string]string
 
+// ErrUnsupportedFormat is returned by ParseBatches for file types it cannot stream.
+var ErrUnsupportedFormat = errors.New("unsupported format for batch parsing")
+
 // Detect and parse file types: CSV, TSV, simple text (key=value per line), and basic Excel (xlsx) placeholder.
 
 func ParseBatches(filename string, r io.Reader, batchSize int, handler func([]Record) error) error {
+	return ParseBatchesFrom(filename, r, batchSize, 0, handler)
+}
+
+// Resumable reports whether ParseBatchesFrom can skip records of the file's format.
+func Resumable(filename string) bool {
+	switch contentTypeFromExt(filename) {
+	case "text/csv", "text/tab-separated-values":
+		return true
+	}
+	return false
+}
+
+// ParseBatchesFrom is ParseBatches that first skips the given number of data records,
+// to resume an import after the records it already committed.
+func ParseBatchesFrom(filename string, r io.Reader, batchSize int, skip int64, handler func([]Record) error) error {
 	if batchSize <= 0 {
 		batchSize = 1000 // default batch size
 	}
@@ -25,14 +43,14 @@ func ParseBatches(filename string, r io.Reader, batchSize int, handler func([]Re
 	ct := contentTypeFromExt(filename)
 	switch ct {
 	case "text/csv":
-		return parseCSVBatch(r, ',', batchSize, handler)
+		return parseCSVBatch(r, ',', batchSize, skip, handler)
 	case "text/tab-separated-values":
-		return parseCSVBatch(r, '\t', batchSize, handler)
+		return parseCSVBatch(r, '\t', batchSize, skip, handler)
 	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
-		return errors.New("xlsx parsing requires full file; provide .csv/.tsv for now")
+		return fmt.Errorf("%w: xlsx parsing requires full file; provide .csv/.tsv for now", ErrUnsupportedFormat)
 	default:
 		// For key-value pairs, we can batch process lines
-		return errors.New("kv parsing does not support batch processing; use Parse function instead")
+		return fmt.Errorf("%w: kv parsing does not support batch processing; use Parse function instead", ErrUnsupportedFormat)
 	}
 }
 
@@ -69,7 +87,7 @@ func contentTypeFromExt(name string) string {
 	return "text/plain"
 }
 
-func parseCSVBatch(r io.Reader, sep rune, batchSize int, handler func([]Record) error) error {
+func parseCSVBatch(r io.Reader, sep rune, batchSize int, skip int64, handler func([]Record) error) error {
 	cr := csv.NewReader(r)
 	cr.Comma = sep
 	cr.TrimLeadingSpace = true
@@ -77,6 +95,14 @@ func parseCSVBatch(r io.Reader, sep rune, batchSize int, handler func([]Record)
 	if err != nil {
 		return err
 	}
+	for ; skip > 0; skip-- {
+		if _, err := cr.Read(); err != nil {
+			if err == io.EOF {
+				return handler(nil)
+			}
+			return err
+		}
+	}
 
 	batch := make([]Record, 0, batchSize)
 	startTime := time.Now()
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResumable(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"users.csv", true},
+		{"USERS.CSV", true},
+		{"users.tsv", true},
+		{"users.tab", true},
+		{"dir.csv/users", false},
+		{"users.xlsx", false},
+		{"users.txt", false},
+		{"users.json", false},
+		{"users", false},
+	}
+	for _, tt := range tests {
+		if got := Resumable(tt.filename); got != tt.want {
+			t.Errorf("Resumable(%q) = %t, want %t", tt.filename, got, tt.want)
+		}
+		// every format ParseBatchesFrom streams can be resumed
+		err := ParseBatchesFrom(tt.filename, strings.NewReader("id\n1\n"), 10, 1, func([]Record) error { return nil })
+		if streams := !errors.Is(err, ErrUnsupportedFormat); streams != tt.want {
+			t.Errorf("ParseBatchesFrom(%q) = %v, want Resumable %t", tt.filename, err, tt.want)
+		}
+	}
+}
+
+func TestParseBatchesFromSkip(t *testing.T) {
+	const csv = "id, name\n1,a\n2,b\n3,c\n4,d\n5,e\n"
+	tests := []struct {
+		name      string
+		filename  string
+		content   string
+		batchSize int
+		skip      int64
+		want      [][]string
+	}{
+		{"no skip", "u.csv", csv, 2, 0, [][]string{{"1", "2"}, {"3", "4"}, {"5"}}},
+		{"skip within batch", "u.csv", csv, 2, 1, [][]string{{"2", "3"}, {"4", "5"}, {}}},
+		{"skip whole batches", "u.csv", csv, 2, 4, [][]string{{"5"}}},
+		{"skip everything", "u.csv", csv, 2, 5, [][]string{{}}},
+		{"skip past the end", "u.csv", csv, 2, 9, [][]string{{}}},
+		{"tsv", "u.tsv", strings.ReplaceAll(csv, ",", "\t"), 10, 3, [][]string{{"4", "5"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			err := ParseBatchesFrom(tt.filename, strings.NewReader(tt.content), tt.batchSize, tt.skip, func(recs []Record) error {
+				ids := []string{}
+				for _, r := range recs {
+					ids = append(ids, r["id"])
+					if r["name"] == "" {
+						t.Errorf("record %v has no name", r)
+					}
+				}
+				got = append(got, ids)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseBatchesFrom: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("batches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
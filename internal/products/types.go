@@ -18,6 +18,11 @@ var supported = map[string]struct{}{
 	ProductCourses:       {},
 }
 
+// Types returns the supported product types in a stable order.
+func Types() []string {
+	return []string{ProductUsers, ProductOrganizations, ProductCourses}
+}
+
 // ValidateProductType checks if the product type is supported.
 func ValidateProductType(pt string) error {
 	if _, ok := supported[pt]; !ok {
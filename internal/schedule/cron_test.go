@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// 2026-10-18 is a Sunday
+	sunday := utc("2026-10-18 10:07:30")
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", sunday, utc("2026-10-18 10:15:00")},
+		{"5/20 * * * *", sunday, utc("2026-10-18 10:25:00")},
+		{"0 9-17/4 * * *", sunday, utc("2026-10-18 13:00:00")},
+		{"0 6 * * *", sunday, utc("2026-10-19 06:00:00")},
+		{"0 6 * * *", utc("2026-10-19 06:00:00"), utc("2026-10-20 06:00:00")},
+		{"@hourly", sunday, utc("2026-10-18 11:00:00")},
+		{"@monthly", sunday, utc("2026-11-01 00:00:00")},
+		{"0 0 1 JAN,jul *", sunday, utc("2027-01-01 00:00:00")},
+		{"0 0 * * MON", sunday, utc("2026-10-19 00:00:00")},
+		{"0 0 * * 7", sunday, utc("2026-10-25 00:00:00")},
+		{"0 0 * * 1-5", utc("2026-10-16 12:00:00"), utc("2026-10-19 00:00:00")},
+		// both day fields restricted: either one matching fires
+		{"0 0 13 * FRI", sunday, utc("2026-10-23 00:00:00")},
+		{"0 12 1-7 * 1", sunday, utc("2026-10-19 12:00:00")},
+		{"0 0 29 2 *", sunday, utc("2028-02-29 00:00:00")},
+		{"0 0 30 2 *", sunday, time.Time{}},
+		{"0 0 31 * *", utc("2026-11-01 00:00:00"), utc("2026-12-31 00:00:00")},
+		// clocks go back at 02:00 EDT on 2026-11-01: 01:30 happens twice but fires once
+		{"30 1 * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 2, 1, 30, 0, 0, ny)},
+		{"30 1 * * *", time.Date(2026, 10, 31, 12, 0, 0, 0, ny), time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)},
+		{"0 12 * * *", time.Date(2026, 10, 31, 13, 0, 0, 0, ny), time.Date(2026, 11, 1, 17, 0, 0, 0, time.UTC)},
+		// clocks go forward at 02:00 EST on 2027-03-14: a time in the gap is skipped
+		{"30 2 * * *", time.Date(2027, 3, 13, 12, 0, 0, 0, ny), time.Date(2027, 3, 15, 2, 30, 0, 0, ny)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.spec, err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 32 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"x * * * *",
+		"0 0 * FOO *",
+		"@often",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) = nil error", spec)
+		}
+	}
+}
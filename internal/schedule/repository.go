@@ -0,0 +1,197 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	// schedules name IANA zones; embed the database for hosts without one
+	_ "time/tzdata"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/db"
+	"github.com/user/importer/internal/products"
+)
+
+var (
+	// ErrNotFound is returned when a schedule id does not exist.
+	ErrNotFound = errors.New("schedule not found")
+	// ErrInvalid wraps validation failures of a schedule's fields.
+	ErrInvalid = errors.New("invalid schedule")
+)
+
+// Schedule enqueues an import job each time its cron expression fires.
+type Schedule struct {
+	ID          int64  `json:"id"`
+	CustomerID  string `json:"customer_id"`
+	ProductType string `json:"product_type"`
+	// BlobURITemplate is rendered with RenderURI at each fire time.
+	BlobURITemplate string `json:"blob_uri_template"`
+	Cron            string `json:"cron"`
+	// Timezone is the IANA zone the cron expression and date placeholders use.
+	Timezone  string     `json:"timezone"`
+	Priority  int        `json:"priority"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt time.Time  `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastJobID *int64     `json:"last_job_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// nextRun validates the schedule's fields and returns its next fire time after t.
+func (s *Schedule) nextRun(t time.Time) (time.Time, error) {
+	if s.CustomerID == "" {
+		return time.Time{}, fmt.Errorf("%w: customer_id is required", ErrInvalid)
+	}
+	if err := products.ValidateProductType(s.ProductType); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: timezone: %v", ErrInvalid, err)
+	}
+	c, err := ParseCron(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if s.BlobURITemplate == "" {
+		return time.Time{}, fmt.Errorf("%w: blob_uri_template is required", ErrInvalid)
+	}
+	if _, err := RenderURI(s.BlobURITemplate, t); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	next := c.Next(t.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: cron %q never fires", ErrInvalid, s.Cron)
+	}
+	return next, nil
+}
+
+// scheduleColumns is the column list scanned by scanSchedule.
+const scheduleColumns = `id, customer_id, product_type, blob_uri_template, cron_expr, timezone, priority, enabled, next_run_at, last_run_at, last_job_id, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*Schedule, error) {
+	var s Schedule
+	if err := row.Scan(&s.ID, &s.CustomerID, &s.ProductType, &s.BlobURITemplate, &s.Cron, &s.Timezone, &s.Priority, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.LastJobID, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Repository stores schedules in the app DB.
+type Repository struct {
+	DB *db.AppDB
+	// Blobs checks the rendered URIs, so a schedule cannot name a URI that Enqueue
+	// would refuse (an unsupported scheme, a path outside the customer's file roots).
+	// Nil skips the check.
+	Blobs blob.Checker
+}
+
+func NewRepository(adb *db.AppDB, blobs blob.Checker) *Repository {
+	return &Repository{DB: adb, Blobs: blobs}
+}
+
+// validate checks the schedule's fields and that its template rendered now is a URI
+// the blob checker accepts, returning the next fire time after now.
+func (r *Repository) validate(ctx context.Context, s *Schedule) (time.Time, error) {
+	now := time.Now()
+	next, err := s.nextRun(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := r.checkURI(ctx, s, now); err != nil {
+		return time.Time{}, fmt.Errorf("%w: blob_uri_template: %v", ErrInvalid, err)
+	}
+	return next, nil
+}
+
+// checkURI renders the schedule's template at t in its timezone and checks the URI
+// for the schedule's customer.
+func (r *Repository) checkURI(ctx context.Context, s *Schedule, t time.Time) error {
+	if r.Blobs == nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return err
+	}
+	uri, err := RenderURI(s.BlobURITemplate, t.In(loc))
+	if err != nil {
+		return err
+	}
+	return r.Blobs.Check(blob.WithCustomer(ctx, s.CustomerID), uri)
+}
+
+// Create validates and stores a new schedule, computing its first run.
+func (r *Repository) Create(ctx context.Context, s Schedule) (*Schedule, error) {
+	next, err := r.validate(ctx, &s)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedule(r.DB.Pool.QueryRow(ctx, `
+INSERT INTO import_schedules(customer_id, product_type, blob_uri_template, cron_expr, timezone, priority, enabled, next_run_at)
+VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING `+scheduleColumns,
+		s.CustomerID, s.ProductType, s.BlobURITemplate, s.Cron, s.Timezone, s.Priority, s.Enabled, next))
+}
+
+// Update replaces a schedule's fields and recomputes its next run from now.
+func (r *Repository) Update(ctx context.Context, s Schedule) (*Schedule, error) {
+	next, err := r.validate(ctx, &s)
+	if err != nil {
+		return nil, err
+	}
+	out, err := scanSchedule(r.DB.Pool.QueryRow(ctx, `
+UPDATE import_schedules SET customer_id=$2, product_type=$3, blob_uri_template=$4, cron_expr=$5, timezone=$6,
+  priority=$7, enabled=$8, next_run_at=$9
+WHERE id=$1 RETURNING `+scheduleColumns,
+		s.ID, s.CustomerID, s.ProductType, s.BlobURITemplate, s.Cron, s.Timezone, s.Priority, s.Enabled, next))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return out, err
+}
+
+func (r *Repository) Get(ctx context.Context, id int64) (*Schedule, error) {
+	s, err := scanSchedule(r.DB.Pool.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM import_schedules WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+// List returns schedules ordered by id, optionally only those of one customer.
+func (r *Repository) List(ctx context.Context, customerID string) ([]Schedule, error) {
+	rows, err := r.DB.Pool.Query(ctx, `SELECT `+scheduleColumns+` FROM import_schedules
+WHERE ($1 = '' OR customer_id = $1) ORDER BY id`, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+// Delete removes a schedule. Jobs it already enqueued are kept.
+func (r *Repository) Delete(ctx context.Context, id int64) error {
+	tag, err := r.DB.Pool.Exec(ctx, `DELETE FROM import_schedules WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/db/dbtest"
+	"github.com/user/importer/internal/jobs"
+)
+
+// fileOnly returns a blob registry serving only file URIs, under root when it is set.
+func fileOnly(root string) *blob.Registry {
+	g := blob.NewRegistry()
+	fb := blob.FileBlob{}
+	if root != "" {
+		fb.Roots = []string{root}
+	}
+	g.Register("file", fb)
+	return g
+}
+
+func testSchedule(tmpl string) Schedule {
+	return Schedule{CustomerID: "c1", ProductType: "users", BlobURITemplate: tmpl, Cron: "0 6 * * *", Enabled: true}
+}
+
+func TestCreateChecksRenderedURI(t *testing.T) {
+	ctx := context.Background()
+	r := NewRepository(dbtest.AppDB(t), fileOnly(""))
+
+	if _, err := r.Create(ctx, testSchedule("s3://bucket/users-{{date:2006-01-02}}.csv")); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Create with an unregistered scheme: err = %v, want ErrInvalid", err)
+	}
+	sc, err := r.Create(ctx, testSchedule("file:///data/users-{{date:2006-01-02}}.csv"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sc.BlobURITemplate = "ftp://host/users.csv"
+	if _, err := r.Update(ctx, *sc); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Update with an unregistered scheme: err = %v, want ErrInvalid", err)
+	}
+	got, err := r.Get(ctx, sc.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.BlobURITemplate != "file:///data/users-{{date:2006-01-02}}.csv" {
+		t.Fatalf("template after refused update = %q", got.BlobURITemplate)
+	}
+}
+
+func TestTickDisablesRefusedSchedule(t *testing.T) {
+	ctx := context.Background()
+	adb := dbtest.AppDB(t)
+	// saved before the scheme was dropped from the registry
+	sc, err := NewRepository(adb, nil).Create(ctx, testSchedule("s3://bucket/users.csv"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := adb.Pool.Exec(ctx, `UPDATE import_schedules SET next_run_at=$2 WHERE id=$1`, sc.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("make schedule due: %v", err)
+	}
+
+	jr := jobs.NewRepository(adb)
+	n, err := NewScheduler(NewRepository(adb, fileOnly("")), jr).Tick(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("Tick = %d, %v; want 0 jobs", n, err)
+	}
+	got, err := NewRepository(adb, nil).Get(ctx, sc.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Enabled || got.LastJobID != nil {
+		t.Fatalf("schedule after tick = enabled %t, last job %v; want disabled without a job", got.Enabled, got.LastJobID)
+	}
+	if list, _, err := jr.List(ctx, jobs.ListFilter{}); err != nil || len(list) != 0 {
+		t.Fatalf("jobs = %d, %v; want none", len(list), err)
+	}
+}
+
+func TestScheduleSandboxedToFileRoots(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	r := NewRepository(dbtest.AppDB(t), fileOnly(root))
+
+	tests := []struct {
+		tmpl string
+		ok   bool
+	}{
+		{"file://" + root + "/users-{{date:2006-01-02}}.csv", true},
+		{"file:///etc/passwd", false},
+		{"file://" + root + "/../../etc/users-{{date:2006-01-02}}.csv", false},
+		{"/etc/users-{{date:2006}}.csv", false},
+	}
+	for _, tt := range tests {
+		_, err := r.Create(ctx, testSchedule(tt.tmpl))
+		if tt.ok && err != nil {
+			t.Errorf("Create(%s) = %v, want nil", tt.tmpl, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalid) {
+			t.Errorf("Create(%s) = %v, want ErrInvalid", tt.tmpl, err)
+		}
+	}
+}
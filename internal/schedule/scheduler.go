@@ -0,0 +1,189 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/user/importer/internal/blob"
+	"github.com/user/importer/internal/jobs"
+)
+
+// DefaultInterval is how often the scheduler looks for due schedules, and how often a
+// standby replica retries for leadership.
+const DefaultInterval = 30 * time.Second
+
+// leaderLockKey names the session advisory lock held by the active scheduler.
+const leaderLockKey = "import_scheduler_leader"
+
+// dueBatch bounds the schedules fired per tick.
+const dueBatch = 100
+
+// Scheduler enqueues jobs for due schedules. Every replica runs one, but only the
+// replica holding the leader advisory lock fires schedules; the lock is tied to a
+// dedicated connection, so it is released when the leader exits or loses the database.
+type Scheduler struct {
+	Repo *Repository
+	Jobs *jobs.Repository
+	// Interval is the tick period; DefaultInterval when zero.
+	Interval time.Duration
+}
+
+func NewScheduler(repo *Repository, jr *jobs.Repository) *Scheduler {
+	return &Scheduler{Repo: repo, Jobs: jr}
+}
+
+// Run campaigns for leadership and fires schedules while leader, until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := s.campaign(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("scheduler:", err)
+		}
+		sleepCtx(ctx, s.interval())
+	}
+}
+
+func (s *Scheduler) interval() time.Duration {
+	if s.Interval > 0 {
+		return s.Interval
+	}
+	return DefaultInterval
+}
+
+// campaign holds a dedicated connection, retrying the leader lock on it every interval,
+// and ticks while the lock is held. It returns when the connection fails.
+func (s *Scheduler) campaign(ctx context.Context) error {
+	pc, err := s.Repo.DB.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The lock lives as long as this session, so keep the connection out of the pool.
+	conn := pc.Hijack()
+	defer conn.Close(context.Background())
+
+	for {
+		var leader bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, leaderLockKey).Scan(&leader); err != nil {
+			return fmt.Errorf("leader lock: %w", err)
+		}
+		if leader {
+			break
+		}
+		sleepCtx(ctx, s.interval())
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	fmt.Println("scheduler: acquired leadership")
+
+	t := time.NewTicker(s.interval())
+	defer t.Stop()
+	for {
+		if n, err := s.Tick(ctx); err != nil {
+			fmt.Println("scheduler tick failed:", err)
+		} else if n > 0 {
+			fmt.Printf("scheduler: enqueued %d scheduled job(s)\n", n)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		if err := conn.Ping(ctx); err != nil {
+			return fmt.Errorf("leader connection lost: %w", err)
+		}
+	}
+}
+
+// Tick enqueues a job for every enabled schedule whose next run is due and advances
+// each to its following run. A schedule that missed several runs (e.g. while no
+// replica was up) fires once, not once per missed run. A schedule whose rendered URI
+// the repository's blob checker refuses is disabled instead. It returns the jobs enqueued.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	tx, err := s.Repo.DB.Pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx, `SELECT `+scheduleColumns+` FROM import_schedules
+WHERE enabled AND next_run_at <= now() ORDER BY next_run_at
+FOR UPDATE SKIP LOCKED LIMIT `+fmt.Sprint(dueBatch))
+	if err != nil {
+		return 0, err
+	}
+	var due []*Schedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, sc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	for _, sc := range due {
+		now := time.Now()
+		fireAt := sc.NextRunAt
+		next, verr := sc.nextRun(now)
+		if verr != nil {
+			// e.g. a timezone removed from the zone database; stop firing instead of retrying every tick
+			fmt.Printf("scheduler: disabling schedule %d: %v\n", sc.ID, verr)
+			if _, err := tx.Exec(ctx, `UPDATE import_schedules SET enabled=false WHERE id=$1`, sc.ID); err != nil {
+				return enqueued, err
+			}
+			continue
+		}
+		loc, _ := time.LoadLocation(sc.Timezone)
+		uri, _ := RenderURI(sc.BlobURITemplate, fireAt.In(loc))
+		if s.Repo.Blobs != nil {
+			if err := s.Repo.Blobs.Check(blob.WithCustomer(ctx, sc.CustomerID), uri); err != nil {
+				if !errors.Is(err, blob.ErrUnsupportedScheme) && !errors.Is(err, blob.ErrOutsideRoots) {
+					fmt.Printf("scheduler: checking %s for schedule %d failed: %v\n", uri, sc.ID, err)
+					continue
+				}
+				// the scheme or the file roots changed since the schedule was saved
+				fmt.Printf("scheduler: disabling schedule %d: %v\n", sc.ID, err)
+				if _, err := tx.Exec(ctx, `UPDATE import_schedules SET enabled=false WHERE id=$1`, sc.ID); err != nil {
+					return enqueued, err
+				}
+				continue
+			}
+		}
+		// The key makes a fire enqueue once even if this transaction rolls back after the
+		// job was created and the next tick fires the schedule again.
+		jobID, err := s.Jobs.Enqueue(ctx, sc.CustomerID, sc.ProductType, uri, jobs.EnqueueOptions{
+			Priority:       sc.Priority,
+			IdempotencyKey: fmt.Sprintf("schedule:%d:%d", sc.ID, fireAt.Unix()),
+		})
+		if err != nil {
+			// left due, so the next tick retries it; the other schedules still fire
+			fmt.Printf("scheduler: enqueue for schedule %d failed: %v\n", sc.ID, err)
+			continue
+		}
+		s.Jobs.Log(ctx, jobID, "info", "job enqueued by schedule", []byte(fmt.Sprintf(`{"schedule_id":%d,"fire_at":%q}`, sc.ID, fireAt.Format(time.RFC3339))))
+		if _, err := tx.Exec(ctx, `
+UPDATE import_schedules SET next_run_at=$2, last_run_at=$3, last_job_id=$4 WHERE id=$1`, sc.ID, next, fireAt, jobID); err != nil {
+			return enqueued, err
+		}
+		enqueued++
+	}
+	return enqueued, tx.Commit(ctx)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
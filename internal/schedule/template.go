@@ -0,0 +1,37 @@
+package schedule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// RenderURI expands the placeholders of a blob URI template for a run at t.
+// {{date:LAYOUT}} formats t with a Go time layout, e.g. {{date:2006-01-02}}.
+// Unknown placeholders are an error so typos do not produce literal paths.
+func RenderURI(tmpl string, t time.Time) (string, error) {
+	var firstErr error
+	out := placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		inner := strings.TrimSpace(m[2 : len(m)-2])
+		name, arg, _ := strings.Cut(inner, ":")
+		switch strings.TrimSpace(name) {
+		case "date":
+			if arg == "" {
+				arg = "2006-01-02"
+			}
+			return t.Format(arg)
+		default:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unknown placeholder %s in %q", m, tmpl)
+			}
+			return m
+		}
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
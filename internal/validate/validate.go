@@ -24,4 +24,22 @@ func Records(productType string, records []parser.Record) error {
 	return nil
 }
 
+// CountInvalid returns how many records lack a required field of the product schema.
+func CountInvalid(productType string, records []parser.Record) int {
+	req, err := products.SchemaRequiredFields(productType)
+	if err != nil {
+		return len(records)
+	}
+	n := 0
+	for _, rec := range records {
+		for _, field := range req {
+			if rec[field] == "" {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
 